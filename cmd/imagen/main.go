@@ -24,6 +24,15 @@ func main() {
 	case "serve":
 		cmd := &cli.ServeCommand{}
 		err = cmd.Execute(args)
+	case "url":
+		cmd := &cli.URLCommand{}
+		err = cmd.Execute(args)
+	case "flags":
+		cmd := &cli.FlagsCommand{}
+		err = cmd.Execute(args)
+	case "fonts":
+		cmd := &cli.FontsCommand{}
+		err = cmd.Execute(args)
 	case "help", "-h", "--help":
 		printUsage()
 		return
@@ -40,33 +49,84 @@ func main() {
 }
 
 func printUsage() {
-	fmt.Println(`imagen - A small image creation utility
+	fmt.Print(`imagen - A small image creation utility
 
 Usage:
   imagen generate [options]  Generate static placeholder images
   imagen serve [options]     Start web server to serve placeholder images
+  imagen url [options]       Print the server URL(s) for the given generate options
+  imagen flags URL           Print the generate command line for the given server URL
+  imagen fonts [--json]      List the embedded and installed fonts usable with --font
   imagen help                Show this help message
 
 Generate Options:
   --size, -s WxH            Image size (width x height), can be repeated
-  --color-mode, -m MODE     Color mode: solid, tiled, gradient, noise (can be repeated)
-  --color, -c COLOR         Color value (name, hex, or 'random'), can be repeated
-  --border-width, -b WIDTH  Border width in pixels
-  --border-color COLOR      Border color
-  --gradient-angle, -a DEG  Gradient angle in degrees (0=top-down, 180=bottom-up)
-  --tile-size, -ts SIZE     Tile size in pixels for tiled/noise mode
-  --text, -t TEXT           Text to display (use {w} and {h} for placeholders)
+  --color, -c COLOR[:t:TC]  Solid color (name, hex, or 'random'), can be repeated
+  --gradient, -g COLORS[:A] Linear gradient: colors and angle in degrees (0=top-down)
+  --radial COLORS[:R][:X,Y] Radial gradient: colors, radius and center in percent
+  --conic COLORS[:A][:X,Y]  Conic gradient: colors, start angle and center in percent
+  --tiles, -t COLORS[:SIZE] Tiles: colors and tile size in pixels
+  --noise, -n COLORS[:SIZE] Noise: colors and tile size in pixels
+                            All backgrounds accept :t:TEXTCOLOR and can be repeated
+  --border, -b W[,COLOR]    Border width in pixels and color
+  --text TEXT               Text to display (use {w} and {h} for placeholders)
   --text-size SIZE          Text size in pt
   --text-color COLOR        Text color
+  --text-angle DEG          Text angle in degrees
+  --text-wrap PCT           Wrap the text at this width in percent of the image width
+  --line-height N           Line height as a multiple of the font's line height (default: 1)
+  --font FONT               Font file or installed font name (e.g. "DejaVu Sans Bold")
+  --seed N                  Random seed for reproducible random colors and noise
   --filename, -f NAME       Output filename (use {w}, {h}, {nr} for placeholders)
-  --format FORMAT           Output format: png, jpeg
+  --format FORMAT           Output format: png, jpeg, webp, gif, apng, svg
+  --quality Q               Encoder quality 1-100 for jpeg and lossy webp (default: 90)
+  --lossless                Encode webp losslessly
+  --frames N                Number of animation frames (gif, apng)
+  --delay MS                Delay between animation frames in milliseconds
+  --frame-angle DEG         Gradient angle change per frame
+  --frame-shift             Shift the colors by one position per frame
+  --nr, -r N                Number of runs, each generating all sizes and backgrounds
+  --manifest FILE           Generate all images of a manifest file (YAML or JSON)
+  --jobs N                  Number of images rendered concurrently (default: number of CPUs)
 
 Serve Options:
+  --config FILE             Configuration file (YAML or JSON) with server settings,
+                            image defaults and presets (p:name in the URL)
   --listen ADDR             Listen address(es), comma-separated (default: :3000)
                             Examples: ":3000", "192.168.1.20:5555", "[::1]:4567"
+  --base-path PATH          Path prefix of all image URLs, e.g. /placeholders
+  --metrics-path PATH       Path of the Prometheus metrics, empty disables them (default: /metrics)
+  --log-format FORMAT       Log format: human or json (default: human)
+  --log-level LEVEL         Minimum log level: debug, info, warn or error (default: info)
+  --tls-cert FILE           TLS certificate file (PEM)
+  --tls-key FILE            TLS private key file (PEM)
+  --tls-self-signed         Serve TLS with a self-signed certificate for localhost
+                            Prefix addresses with http:// or https:// to mix plain and TLS
+  --cache-size MB           Image cache size in MB, 0 disables the cache (default: 64)
+  --cache-entries N         Maximum number of cached images (default: 1000)
+  --max-width, --max-height PX  Maximum image width / height (default: 8192)
+  --max-pixels N            Maximum pixels, width x height x frames (default: 40000000)
+  --max-frames N            Maximum animation frames (default: 100)
+  --max-text N              Maximum text length (default: 500)
+  --max-text-size PT        Maximum text size in pt (default: 1000)
+  --max-border PX           Maximum border width (default: 1000)
+  --max-concurrent N        Maximum concurrent renders (default: number of CPUs)
+  --render-timeout DUR      Render timeout per request (default: 30s)
+  --read-timeout DUR        HTTP read timeout (default: 10s)
+  --write-timeout DUR       HTTP write timeout (default: 60s)
+  --idle-timeout DUR        HTTP keep-alive idle timeout (default: 120s)
+  --shutdown-timeout DUR    Graceful shutdown timeout on SIGINT/SIGTERM (default: 30s)
+
+  Admin endpoints (JSON): /_imagen/health, /_imagen/ready, /_imagen/version, /_imagen/stats,
+                          /_imagen/fonts
+
+URL Options:
+  All generate options, plus:
+  --base URL                Base URL of the imagen server (e.g. http://localhost:3000)
 
 URL Format (for serve mode):
-  http://[host]/[size]/c:[color]/t:[text]/f:[format]/b:[border]
+  http://[host]/[size]/c:[color]/t:[text]/f:[format]/b:[border]/a:[frames],d:[delay]
+  http://[host]/[size].[format]?bg=[mode]&colors=[colors]&text=[text]&textSize=[size]
 
   Example:
     http://localhost:3000/400x300/c:blue/t:"hello, world",s:26,c:yellow/f:png/b:5,ffffff
@@ -76,12 +136,19 @@ Examples:
   imagen generate --size 512x384 --color blue
 
   # Generate multiple images with different sizes and gradients
-  imagen generate -s 400x300 -s 800x600 -m gradient -c red -c yellow
+  imagen generate -s 400x300 -s 800x600 -g red,yellow
+
+  # Convert generate options to a server URL, and back
+  imagen url --base http://localhost:3000 -s 400x300 -g red,blue:45:t:white
+  imagen flags http://localhost:3000/400x300/g:red,blue:45:t:white
 
   # Start server on port 8080
   imagen serve --listen :8080
 
   # Start server on multiple addresses
   imagen serve --listen ":3000,:8080"
+
+  # Serve HTTP and HTTPS (HTTP/2) with a self-signed certificate
+  imagen serve --listen "http://:3000,https://:3443" --tls-self-signed
 `)
 }
@@ -2,6 +2,14 @@ module github.com/bylexus/imagen
 
 go 1.23
 
-require golang.org/x/image v0.23.0
+require (
+	github.com/gen2brain/webp v0.5.5
+	golang.org/x/image v0.23.0
+	gopkg.in/yaml.v3 v3.0.1
+)
 
-require golang.org/x/text v0.21.0 // indirect
+require (
+	github.com/ebitengine/purego v0.8.3 // indirect
+	github.com/tetratelabs/wazero v1.9.0 // indirect
+	golang.org/x/text v0.21.0 // indirect
+)
@@ -0,0 +1,204 @@
+package cli
+
+import (
+	"flag"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/bylexus/imagen/pkg/generator"
+	"github.com/bylexus/imagen/pkg/spec"
+)
+
+// backgroundFlags maps the color modes to their 'generate' flag names
+var backgroundFlags = map[generator.ColorMode]string{
+	generator.ColorModeSolid:    "color",
+	generator.ColorModeGradient: "gradient",
+	generator.ColorModeRadial:   "radial",
+	generator.ColorModeConic:    "conic",
+	generator.ColorModeTiled:    "tiles",
+	generator.ColorModeNoise:    "noise",
+}
+
+// URLCommand handles the 'url' command: it converts 'generate' flags into server URLs
+type URLCommand struct {
+	generate GenerateCommand
+	base     string
+}
+
+// Execute runs the url command
+func (c *URLCommand) Execute(args []string) error {
+	fs := c.generate.flagSet("url")
+	fs.StringVar(&c.base, "base", "", "Base URL of the imagen server, e.g. http://localhost:3000")
+
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() > 0 {
+		return fmt.Errorf("unexpected argument: %s", fs.Arg(0))
+	}
+
+	if len(c.generate.sizes) == 0 {
+		c.generate.sizes = []string{fmt.Sprintf("%dx%d", spec.DefaultWidth, spec.DefaultHeight)}
+	}
+
+	// The server only accepts installed fonts, selected by name
+	if generator.IsFontPath(c.generate.font) {
+		return fmt.Errorf("--font must be an installed font name for URLs, not a font file")
+	}
+
+	baseSpec, err := c.generate.Spec()
+	if err != nil {
+		return err
+	}
+	base := strings.TrimSuffix(c.base, "/")
+
+	// One URL per generated image: sizes * backgrounds
+	for _, sizeStr := range c.generate.sizes {
+		width, height, err := spec.ParseSize(sizeStr)
+		if err != nil {
+			return fmt.Errorf("invalid size %s: %w", sizeStr, err)
+		}
+
+		imageSpec := *baseSpec
+		imageSpec.Width = width
+		imageSpec.Height = height
+
+		if len(baseSpec.Backgrounds) == 0 {
+			fmt.Println(base + imageSpec.EscapedPath())
+			continue
+		}
+		for _, bg := range baseSpec.Backgrounds {
+			imageSpec.Backgrounds = []spec.Background{bg}
+			fmt.Println(base + imageSpec.EscapedPath())
+		}
+	}
+
+	return nil
+}
+
+// FlagsCommand handles the 'flags' command: it converts a server URL into a 'generate' command line
+type FlagsCommand struct{}
+
+// Execute runs the flags command
+func (c *FlagsCommand) Execute(args []string) error {
+	fs := flag.NewFlagSet("flags", flag.ExitOnError)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		return fmt.Errorf("expected exactly one URL or URL path")
+	}
+
+	// Accept full URLs as well as plain paths, with an optional query string
+	path, rawQuery, _ := strings.Cut(fs.Arg(0), "?")
+	if strings.Contains(path, "://") {
+		u, err := url.Parse(path)
+		if err != nil {
+			return fmt.Errorf("invalid URL: %w", err)
+		}
+		path = u.EscapedPath()
+	}
+
+	s, err := spec.ParseURL(path, rawQuery)
+	if err != nil {
+		return err
+	}
+
+	args = append([]string{"imagen", "generate"}, generateArgs(s)...)
+	for i, arg := range args {
+		args[i] = shellQuote(arg)
+	}
+	fmt.Println(strings.Join(args, " "))
+
+	return nil
+}
+
+// generateArgs returns the 'generate' command line arguments describing the spec.
+// Default values are omitted.
+func generateArgs(s *spec.Spec) []string {
+	args := []string{"--size", fmt.Sprintf("%dx%d", s.Width, s.Height)}
+
+	for _, bg := range s.Backgrounds {
+		args = append(args, "--"+backgroundFlags[bg.Mode], bg.String())
+	}
+
+	if s.Text != spec.DefaultText {
+		args = append(args, "--text", s.Text)
+	}
+	if s.TextSize != spec.DefaultTextSize {
+		args = append(args, "--text-size", spec.FormatFloat(s.TextSize))
+	}
+	if s.TextColor != "" {
+		args = append(args, "--text-color", s.TextColor)
+	}
+	if s.TextAngle != 0 {
+		args = append(args, "--text-angle", spec.FormatFloat(s.TextAngle))
+	}
+	if s.TextWrap != 0 {
+		args = append(args, "--text-wrap", spec.FormatFloat(s.TextWrap))
+	}
+	if s.LineHeight != spec.DefaultLineHeight {
+		args = append(args, "--line-height", spec.FormatFloat(s.LineHeight))
+	}
+	if s.Font != "" {
+		args = append(args, "--font", s.Font)
+	}
+
+	if s.BorderWidth > 0 {
+		border := strconv.Itoa(s.BorderWidth)
+		if s.BorderColor != "" {
+			border += "," + s.BorderColor
+		}
+		args = append(args, "--border", border)
+	}
+
+	if s.Format != spec.DefaultFormat {
+		args = append(args, "--format", s.Format)
+	}
+	if s.Quality != spec.DefaultQuality {
+		args = append(args, "--quality", strconv.Itoa(s.Quality))
+	}
+	if s.Lossless {
+		args = append(args, "--lossless")
+	}
+
+	if s.Frames > 1 {
+		args = append(args, "--frames", strconv.Itoa(s.Frames))
+		if s.FrameDelay != spec.DefaultFrameDelay {
+			args = append(args, "--delay", strconv.Itoa(s.FrameDelay))
+		}
+		if s.FrameAngleStep != 0 {
+			args = append(args, "--frame-angle", spec.FormatFloat(s.FrameAngleStep))
+		}
+		if s.FrameColorShift {
+			args = append(args, "--frame-shift")
+		}
+	}
+
+	// A path-derived seed (s:path) is passed as its resolved value
+	if s.Seed != nil {
+		args = append(args, "--seed", strconv.FormatInt(*s.Seed, 10))
+	}
+
+	return args
+}
+
+// shellQuote quotes an argument for POSIX shells, if needed
+func shellQuote(arg string) string {
+	if arg == "" {
+		return "''"
+	}
+	safe := true
+	for _, r := range arg {
+		if !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || strings.ContainsRune("-_.,:/=+@%", r)) {
+			safe = false
+			break
+		}
+	}
+	if safe {
+		return arg
+	}
+	return "'" + strings.ReplaceAll(arg, "'", `'\''`) + "'"
+}
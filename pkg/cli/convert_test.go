@@ -0,0 +1,209 @@
+package cli
+
+import (
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/bylexus/imagen/pkg/spec"
+)
+
+// shellSplit splits a command line produced with shellQuote into its arguments
+func shellSplit(t *testing.T, line string) []string {
+	t.Helper()
+	var args []string
+	var arg strings.Builder
+	inArg, quoted := false, false
+	for i := 0; i < len(line); i++ {
+		switch ch := line[i]; {
+		case quoted && ch == '\'':
+			quoted = false
+		case quoted:
+			arg.WriteByte(ch)
+		case ch == '\'':
+			quoted, inArg = true, true
+		case ch == '\\' && i+1 < len(line):
+			i++
+			arg.WriteByte(line[i])
+			inArg = true
+		case ch == ' ':
+			if inArg {
+				args = append(args, arg.String())
+				arg.Reset()
+				inArg = false
+			}
+		default:
+			arg.WriteByte(ch)
+			inArg = true
+		}
+	}
+	if quoted {
+		t.Fatalf("unterminated quote in %s", line)
+	}
+	if inArg {
+		args = append(args, arg.String())
+	}
+	return args
+}
+
+func TestShellQuote(t *testing.T) {
+	tests := []struct {
+		arg  string
+		want string
+	}{
+		{"", "''"},
+		{"plain", "plain"},
+		{"400x300", "400x300"},
+		{"red,blue:45:t:white", "red,blue:45:t:white"},
+		{"--text-size=20", "--text-size=20"},
+		{"http://localhost:3000/a?b=c", "'http://localhost:3000/a?b=c'"},
+		{"hello world", "'hello world'"},
+		{"it's", `'it'\''s'`},
+		{"'", `''\'''`},
+		{"''", `''\'''\'''`},
+		{`say "hi"`, `'say "hi"'`},
+		{"$HOME", "'$HOME'"},
+		{"*.png", "'*.png'"},
+		{"a;b", "'a;b'"},
+		{"{w}x{h}", "'{w}x{h}'"},
+		{`back\slash`, `'back\slash'`},
+		{"line\nbreak", "'line\nbreak'"},
+		{"größer", "'größer'"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.arg, func(t *testing.T) {
+			got := shellQuote(tt.arg)
+			if got != tt.want {
+				t.Errorf("shellQuote(%q) = %s, want %s", tt.arg, got, tt.want)
+			}
+			if split := shellSplit(t, got); len(split) != 1 || split[0] != tt.arg {
+				t.Errorf("shellQuote(%q) = %s is read by the shell as %q", tt.arg, got, split)
+			}
+		})
+	}
+}
+
+func TestGenerateArgs(t *testing.T) {
+	tests := []struct {
+		path string
+		want []string
+	}{
+		{"/400x300", []string{"--size", "400x300"}},
+		{"/400x300/c:red", []string{"--size", "400x300", "--color", "red"}},
+		{`/400x300/g:red,blue:45:t:white/k:red,blue:90:25,75/t:"hello world",s:32,c:yellow,f:Go Mono`, []string{
+			"--size", "400x300", "--gradient", "red,blue:45:t:white", "--conic", "red,blue:90:25,75",
+			"--text", "hello world", "--text-size", "32", "--text-color", "yellow", "--font", "Go Mono",
+		}},
+		{"/400x300/b:5,ffffff/f:webp,q:70,lossless", []string{"--size", "400x300", "--border", "5,ffffff", "--format", "webp", "--quality", "70", "--lossless"}},
+		{"/400x300/f:gif/a:4,d:50,r:15,shift/s:42", []string{
+			"--size", "400x300", "--format", "gif", "--frames", "4", "--delay", "50", "--frame-angle", "15", "--frame-shift", "--seed", "42",
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			s, err := spec.ParseURL(tt.path, "")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := generateArgs(s); !slices.Equal(got, tt.want) {
+				t.Errorf("generateArgs() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFlagsAndURLRoundTrip(t *testing.T) {
+	paths := []string{
+		"/400x300",
+		"/1x1/c:random",
+		`/400x300/c:blue/t:"hello, world",s:26,c:yellow`,
+		`/400x300/t:"it's a \"quoted\" text",a:-30,w:80,l:1.5`,
+		`/400x300/t:"{w} x {h}",f:Go Mono Bold`,
+		`/400x300/t:""`,
+		"/400x300/g:red,blue:45:t:white/r:white,black:80:20,50/k:red,green,blue:90:25,75/t:e0e0e0,00ff00:8/n:red,blue,green:4",
+		"/640x480/c:ff0000/b:4,white/f:webp,q:70,lossless",
+		"/400x300/g:red,blue/f:apng/a:12,d:80,r:30,shift/s:7",
+	}
+	for _, path := range paths {
+		t.Run(path, func(t *testing.T) {
+			s, err := spec.ParseURL(path, "")
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			// URL -> flags: the printed command line parses to the same spec
+			output := captureStdout(t, func() { err = (&FlagsCommand{}).Execute([]string{"http://localhost:3000" + s.EscapedPath()}) })
+			if err != nil {
+				t.Fatal(err)
+			}
+			args := shellSplit(t, strings.TrimSuffix(output, "\n"))
+			if len(args) < 2 || args[0] != "imagen" || args[1] != "generate" {
+				t.Fatalf("command line = %s, want imagen generate ...", output)
+			}
+			flagsPath, err := parseGenerateFlags(args[2:])
+			if err != nil {
+				t.Fatalf("parsing %q: %v", args, err)
+			}
+			if flagsPath != s.Path() {
+				t.Errorf("flags %s describe %s, want %s", output, flagsPath, s.Path())
+			}
+
+			// flags -> URL: one URL per background, with the same remaining settings
+			output = captureStdout(t, func() { err = (&URLCommand{}).Execute(append(args[2:], "--base", "http://localhost:3000/")) })
+			if err != nil {
+				t.Fatal(err)
+			}
+			urls := strings.Split(strings.TrimSuffix(output, "\n"), "\n")
+			if want := max(1, len(s.Backgrounds)); len(urls) != want {
+				t.Fatalf("url printed %d URLs, want %d:\n%s", len(urls), want, output)
+			}
+			for i, u := range urls {
+				want := *s
+				if len(s.Backgrounds) > 0 {
+					want.Backgrounds = s.Backgrounds[i : i+1]
+				}
+				if u != "http://localhost:3000"+want.EscapedPath() {
+					t.Errorf("URL %d = %s, want http://localhost:3000%s", i+1, u, want.EscapedPath())
+				}
+			}
+		})
+	}
+}
+
+func TestFlagsCommandQuery(t *testing.T) {
+	var err error
+	output := captureStdout(t, func() {
+		err = (&FlagsCommand{}).Execute([]string{"http://localhost:3000/400x300.webp?bg=radial&colors=red,blue&radius=80&text=Hi%20there&font=Go%20Mono"})
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "imagen generate --size 400x300 --radial red,blue:80 --text 'Hi there' --font 'Go Mono' --format webp\n"
+	if output != want {
+		t.Errorf("output = %s, want %s", output, want)
+	}
+}
+
+func TestURLCommand(t *testing.T) {
+	var err error
+	output := captureStdout(t, func() {
+		err = (&URLCommand{}).Execute([]string{"-s", "400x300", "-s", "800x600", "-c", "red", "-g", "red,blue:45", "--text", "hello world"})
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := strings.Join([]string{
+		"/400x300/c:red/t:%22hello%20world%22",
+		"/400x300/g:red,blue:45/t:%22hello%20world%22",
+		"/800x600/c:red/t:%22hello%20world%22",
+		"/800x600/g:red,blue:45/t:%22hello%20world%22",
+	}, "\n") + "\n"
+	if output != want {
+		t.Errorf("output =\n%s\nwant\n%s", output, want)
+	}
+
+	err = (&URLCommand{}).Execute([]string{"--font", "/fonts/custom.ttf"})
+	if err == nil || !strings.Contains(err.Error(), "installed font name") {
+		t.Errorf("url with a font file = %v, want an error", err)
+	}
+}
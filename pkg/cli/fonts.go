@@ -0,0 +1,40 @@
+package cli
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	"github.com/bylexus/imagen/pkg/generator"
+)
+
+// FontsCommand handles the 'fonts' command: it lists the fonts that can be used for texts
+type FontsCommand struct{}
+
+// Execute runs the fonts command
+func (c *FontsCommand) Execute(args []string) error {
+	fs := flag.NewFlagSet("fonts", flag.ExitOnError)
+	asJSON := fs.Bool("json", false, "Print the fonts as JSON")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() > 0 {
+		return fmt.Errorf("unexpected argument: %s", fs.Arg(0))
+	}
+
+	fonts := generator.AvailableFonts()
+	if *asJSON {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(fonts)
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "NAME\tFAMILY\tSTYLE\tPATH\tINDEX")
+	for _, f := range fonts {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%d\n", f.Name(), f.Family, f.Style, f.Path, f.Index)
+	}
+	return w.Flush()
+}
@@ -0,0 +1,73 @@
+package cli
+
+import (
+	"encoding/json"
+	"regexp"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/bylexus/imagen/pkg/generator"
+)
+
+func TestFontsCommandText(t *testing.T) {
+	var err error
+	output := captureStdout(t, func() { err = (&FontsCommand{}).Execute(nil) })
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	lines := strings.Split(strings.TrimSuffix(output, "\n"), "\n")
+	if !regexp.MustCompile(`^NAME\s+FAMILY\s+STYLE\s+PATH\s+INDEX$`).MatchString(lines[0]) {
+		t.Errorf("header = %q, want the column names", lines[0])
+	}
+	if want := len(generator.AvailableFonts()) + 1; len(lines) != want {
+		t.Errorf("%d lines, want a header and one line per font, %d lines", len(lines), want)
+	}
+
+	// The embedded fonts come first, with their names from the font files
+	for _, want := range []string{
+		`^Go Bold\s+Go\s+Bold\s+embedded:Go-Bold\.ttf\s+0$`,
+		`^Go Mono\s+Go Mono\s+Regular\s+embedded:Go-Mono\.ttf\s+0$`,
+		`^Go Regular\s+Go\s+Regular\s+embedded:Go-Regular\.ttf\s+0$`,
+		`^Go Smallcaps Italic\s+Go Smallcaps\s+Italic\s+embedded:Go-Smallcaps-Italic\.ttf\s+0$`,
+	} {
+		re := regexp.MustCompile(want)
+		if !slices.ContainsFunc(lines[1:len(generator.EmbeddedFonts())+1], re.MatchString) {
+			t.Errorf("no embedded font line matches %s", want)
+		}
+	}
+}
+
+func TestFontsCommandJSON(t *testing.T) {
+	var err error
+	output := captureStdout(t, func() { err = (&FontsCommand{}).Execute([]string{"--json"}) })
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var fonts []generator.FontInfo
+	if err := json.Unmarshal([]byte(output), &fonts); err != nil {
+		t.Fatalf("output is no JSON list of fonts: %v\n%s", err, output)
+	}
+	embedded := generator.EmbeddedFonts()
+	if len(fonts) < len(embedded) || !slices.Equal(fonts[:len(embedded)], embedded) {
+		t.Fatalf("fonts = %+v, want the embedded fonts first", fonts)
+	}
+
+	regular := fonts[slices.IndexFunc(fonts, func(f generator.FontInfo) bool { return f.Name() == "Go Regular" })]
+	want := generator.FontInfo{Family: "Go", Style: "Regular", FullName: "Go Regular", Path: "embedded:Go-Regular.ttf", Embedded: true}
+	if regular != want {
+		t.Errorf("Go Regular = %+v, want %+v", regular, want)
+	}
+	if !strings.Contains(output, `"embedded": true`) || strings.Contains(output, `"index": 0`) {
+		t.Errorf("output does not mark the embedded fonts, or lists the default index:\n%s", output)
+	}
+}
+
+func TestFontsCommandRejectsArguments(t *testing.T) {
+	err := (&FontsCommand{}).Execute([]string{"extra"})
+	if err == nil || err.Error() != "unexpected argument: extra" {
+		t.Errorf("Execute() = %v, want an unexpected argument error", err)
+	}
+}
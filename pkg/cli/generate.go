@@ -3,235 +3,281 @@ package cli
 import (
 	"flag"
 	"fmt"
-	"image/color"
-	"os"
+	"runtime"
 	"strconv"
 	"strings"
 
 	"github.com/bylexus/imagen/pkg/generator"
+	"github.com/bylexus/imagen/pkg/spec"
 )
 
-// ColorDefinition holds a parsed color parameter
-type ColorDefinition struct {
-	Mode         generator.ColorMode // solid, gradient, tiled, noise
-	Colors       []color.Color
-	ColorStrings []string // original color strings for regenerating random colors
-	Angle        float64  // for gradient
-	TileSize     int      // for tiled/noise
-	TextColor    *color.Color // optional text color override
-}
-
 // GenerateCommand handles the 'generate' command
 type GenerateCommand struct {
-	sizes           []string
-	colorDefs       []ColorDefinition
-	border          string
-	text            string
-	textSize        float64
-	textColor       string
-	textAngle       float64
-	filename        string
-	format          string
-	rounds          int
+	sizes       []string
+	backgrounds []spec.Background
+	border      string
+	text        string
+	textSize    float64
+	textColor   string
+	textAngle   float64
+	textWrap    float64
+	lineHeight  float64
+	font        string
+	filename    string
+	format      string
+	quality     int
+	lossless    bool
+	frames      int
+	delay       int
+	frameAngle  float64
+	frameShift  bool
+	rounds      int
+	seed        *int64
 }
 
-// Execute runs the generate command
-func (c *GenerateCommand) Execute(args []string) error {
-	fs := flag.NewFlagSet("generate", flag.ExitOnError)
+// flagSet creates the flag set of the generate command, bound to the command's fields
+func (c *GenerateCommand) flagSet(name string) *flag.FlagSet {
+	fs := flag.NewFlagSet(name, flag.ExitOnError)
 
 	// Size flag (can be repeated)
-	fs.Func("size", "Image size (WxH), can be repeated", func(s string) error {
-		c.sizes = append(c.sizes, s)
-		return nil
-	})
-	fs.Func("s", "Image size (WxH), can be repeated (shorthand)", func(s string) error {
-		c.sizes = append(c.sizes, s)
-		return nil
-	})
+	fs.Func("size", "Image size (WxH), can be repeated", c.sizeFlag)
+	fs.Func("s", "Image size (WxH), can be repeated (shorthand)", c.sizeFlag)
 
 	// Color flags (can be repeated) - solid color
-	fs.Func("color", "Solid color: color[:t:textcolor]", func(s string) error {
-		def, err := parseColorParameter(s, generator.ColorModeSolid)
-		if err != nil {
-			return err
-		}
-		c.colorDefs = append(c.colorDefs, def)
-		return nil
-	})
-	fs.Func("c", "Solid color (shorthand)", func(s string) error {
-		def, err := parseColorParameter(s, generator.ColorModeSolid)
-		if err != nil {
-			return err
-		}
-		c.colorDefs = append(c.colorDefs, def)
-		return nil
-	})
+	fs.Func("color", "Solid color: color[:t:textcolor]", c.backgroundFlag(generator.ColorModeSolid))
+	fs.Func("c", "Solid color (shorthand)", c.backgroundFlag(generator.ColorModeSolid))
 
 	// Gradient flags (can be repeated)
-	fs.Func("gradient", "Gradient: color1,color2[,...][:angle][:t:textcolor]", func(s string) error {
-		def, err := parseColorParameter(s, generator.ColorModeGradient)
-		if err != nil {
-			return err
-		}
-		c.colorDefs = append(c.colorDefs, def)
-		return nil
-	})
-	fs.Func("g", "Gradient (shorthand)", func(s string) error {
-		def, err := parseColorParameter(s, generator.ColorModeGradient)
-		if err != nil {
-			return err
-		}
-		c.colorDefs = append(c.colorDefs, def)
-		return nil
-	})
+	fs.Func("gradient", "Gradient: color1,color2[,...][:angle][:t:textcolor]", c.backgroundFlag(generator.ColorModeGradient))
+	fs.Func("g", "Gradient (shorthand)", c.backgroundFlag(generator.ColorModeGradient))
 
 	// Tiles flags (can be repeated)
-	fs.Func("tiles", "Tiles: color1,color2[,...][:tilesize][:t:textcolor]", func(s string) error {
-		def, err := parseColorParameter(s, generator.ColorModeTiled)
-		if err != nil {
-			return err
-		}
-		c.colorDefs = append(c.colorDefs, def)
-		return nil
-	})
-	fs.Func("t", "Tiles (shorthand)", func(s string) error {
-		def, err := parseColorParameter(s, generator.ColorModeTiled)
-		if err != nil {
-			return err
-		}
-		c.colorDefs = append(c.colorDefs, def)
-		return nil
-	})
+	fs.Func("tiles", "Tiles: color1,color2[,...][:tilesize][:t:textcolor]", c.backgroundFlag(generator.ColorModeTiled))
+	fs.Func("t", "Tiles (shorthand)", c.backgroundFlag(generator.ColorModeTiled))
 
 	// Noise flags (can be repeated)
-	fs.Func("noise", "Noise: color1,color2[,...][:tilesize][:t:textcolor]", func(s string) error {
-		def, err := parseColorParameter(s, generator.ColorModeNoise)
-		if err != nil {
-			return err
-		}
-		c.colorDefs = append(c.colorDefs, def)
-		return nil
-	})
-	fs.Func("n", "Noise (shorthand)", func(s string) error {
-		def, err := parseColorParameter(s, generator.ColorModeNoise)
-		if err != nil {
-			return err
-		}
-		c.colorDefs = append(c.colorDefs, def)
-		return nil
-	})
+	fs.Func("noise", "Noise: color1,color2[,...][:tilesize][:t:textcolor]", c.backgroundFlag(generator.ColorModeNoise))
+	fs.Func("n", "Noise (shorthand)", c.backgroundFlag(generator.ColorModeNoise))
+
+	// Radial gradient flags (can be repeated)
+	fs.Func("radial", "Radial gradient: color1,color2[,...][:radius][:cx,cy][:t:textcolor]", c.backgroundFlag(generator.ColorModeRadial))
+
+	// Conic gradient flags (can be repeated)
+	fs.Func("conic", "Conic gradient: color1,color2[,...][:angle][:cx,cy][:t:textcolor]", c.backgroundFlag(generator.ColorModeConic))
 
 	// Border parameter (combined width and color)
-	fs.StringVar(&c.border, "border", "", "Border: width,color")
-	fs.StringVar(&c.border, "b", "", "Border (shorthand)")
+	fs.Func("border", "Border: width[,color]", c.borderFlag)
+	fs.Func("b", "Border (shorthand)", c.borderFlag)
 
 	// Text parameters
-	fs.StringVar(&c.text, "text", "{w}x{h}", "Text to display")
-	fs.Float64Var(&c.textSize, "text-size", 20, "Text size in pt")
-	fs.StringVar(&c.textColor, "text-color", "", "Default text color")
+	fs.StringVar(&c.text, "text", spec.DefaultText, "Text to display")
+	fs.Float64Var(&c.textSize, "text-size", spec.DefaultTextSize, "Text size in pt")
+	fs.Func("text-color", "Default text color", func(s string) error {
+		if _, err := spec.ParseColorValue(s); err != nil {
+			return fmt.Errorf("invalid text color: %w", err)
+		}
+		c.textColor = s
+		return nil
+	})
 	fs.Float64Var(&c.textAngle, "text-angle", 0, "Text angle in degrees")
+	fs.Float64Var(&c.textWrap, "text-wrap", 0, "Wrap the text at this width in percent of the image width, 0 disables wrapping")
+	fs.Float64Var(&c.lineHeight, "line-height", spec.DefaultLineHeight, "Line height as a multiple of the font's line height")
+	fs.StringVar(&c.font, "font", "", "Font file path or installed font name, e.g. \"DejaVu Sans Bold\"")
 
 	// Output parameters
 	fs.StringVar(&c.filename, "filename", "image.png", "Output filename")
 	fs.StringVar(&c.filename, "f", "image.png", "Output filename (shorthand)")
-	fs.StringVar(&c.format, "format", "png", "Output format (png, jpeg)")
+	fs.StringVar(&c.format, "format", spec.DefaultFormat, "Output format (png, jpeg, webp, gif, apng, svg)")
+	fs.IntVar(&c.quality, "quality", spec.DefaultQuality, "Encoder quality 1-100 (jpeg, webp)")
+	fs.BoolVar(&c.lossless, "lossless", false, "Use lossless compression (webp)")
+
+	// Animation parameters
+	fs.IntVar(&c.frames, "frames", 1, "Number of animation frames (gif, apng)")
+	fs.IntVar(&c.delay, "delay", spec.DefaultFrameDelay, "Delay between animation frames in milliseconds")
+	fs.Float64Var(&c.frameAngle, "frame-angle", 0, "Gradient angle change per frame in degrees")
+	fs.BoolVar(&c.frameShift, "frame-shift", false, "Shift the colors by one position per frame")
+
+	// Seed parameter
+	fs.Func("seed", "Random seed for reproducible random colors and noise", func(s string) error {
+		seed, err := strconv.ParseInt(s, 10, 64)
+		if err != nil {
+			return fmt.Errorf("invalid seed: %w", err)
+		}
+		c.seed = &seed
+		return nil
+	})
 
 	// Rounds parameter
 	fs.IntVar(&c.rounds, "nr", 1, "Number of runs")
 	fs.IntVar(&c.rounds, "r", 1, "Number of runs (shorthand)")
 
-	if err := fs.Parse(args); err != nil {
+	return fs
+}
+
+// sizeFlag checks and collects an image size
+func (c *GenerateCommand) sizeFlag(s string) error {
+	if _, _, err := spec.ParseSize(s); err != nil {
+		return fmt.Errorf("invalid size: %w", err)
+	}
+	c.sizes = append(c.sizes, s)
+	return nil
+}
+
+// borderFlag checks and sets the border
+func (c *GenerateCommand) borderFlag(s string) error {
+	if _, _, err := spec.ParseBorder(s); err != nil {
 		return err
 	}
+	c.border = s
+	return nil
+}
 
-	// Set defaults if not provided
-	if len(c.sizes) == 0 {
-		c.sizes = []string{"256x192"}
+// backgroundFlag returns a flag function that parses and collects a background of the given mode
+func (c *GenerateCommand) backgroundFlag(mode generator.ColorMode) func(string) error {
+	return func(s string) error {
+		bg, err := spec.ParseBackground(mode, s)
+		if err != nil {
+			return err
+		}
+		c.backgrounds = append(c.backgrounds, bg)
+		return nil
 	}
-	if len(c.colorDefs) == 0 {
-		// Default: solid gray
-		c.colorDefs = []ColorDefinition{
-			{
-				Mode:   generator.ColorModeSolid,
-				Colors: []color.Color{color.Gray{128}},
-			},
+}
+
+// Spec builds the image spec from the parsed flags, using the first size
+func (c *GenerateCommand) Spec() (*spec.Spec, error) {
+	s := spec.New()
+	s.Backgrounds = c.backgrounds
+	s.Text = c.text
+	s.TextSize = c.textSize
+	s.TextAngle = c.textAngle
+	s.TextWrap = c.textWrap
+	s.LineHeight = c.lineHeight
+	s.Font = c.font
+	s.Format = strings.ToLower(c.format)
+	s.Quality = c.quality
+	s.Lossless = c.lossless
+	s.Frames = c.frames
+	s.FrameDelay = c.delay
+	s.FrameAngleStep = c.frameAngle
+	s.FrameColorShift = c.frameShift
+	s.Seed = c.seed
+
+	if len(c.sizes) > 0 {
+		width, height, err := spec.ParseSize(c.sizes[0])
+		if err != nil {
+			return nil, fmt.Errorf("invalid size %s: %w", c.sizes[0], err)
 		}
+		s.Width = width
+		s.Height = height
 	}
 
 	// Parse border if provided
-	var borderWidth int
-	var borderColor color.Color = color.Black
 	if c.border != "" {
-		parts := strings.Split(c.border, ",")
-		if len(parts) != 2 {
-			return fmt.Errorf("border must be in format width,color")
-		}
-		var err error
-		borderWidth, err = strconv.Atoi(strings.TrimSpace(parts[0]))
-		if err != nil {
-			return fmt.Errorf("invalid border width: %w", err)
-		}
-		borderColor, err = generator.ParseColor(strings.TrimSpace(parts[1]))
+		width, col, err := spec.ParseBorder(c.border)
 		if err != nil {
-			return fmt.Errorf("invalid border color: %w", err)
+			return nil, err
 		}
+		s.BorderWidth = width
+		s.BorderColor = col
 	}
 
 	// Parse default text color if provided
-	var defaultTextColor *color.Color
 	if c.textColor != "" {
-		col, err := generator.ParseColor(c.textColor)
+		col, err := spec.ParseColorValue(c.textColor)
 		if err != nil {
-			return fmt.Errorf("invalid text color: %w", err)
+			return nil, fmt.Errorf("invalid text color: %w", err)
 		}
-		defaultTextColor = &col
+		s.TextColor = col
 	}
 
-	// Generate images: sizes * color definitions * rounds
+	if err := s.Validate(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// Execute runs the generate command
+func (c *GenerateCommand) Execute(args []string) error {
+	fs := c.flagSet("generate")
+	manifest := fs.String("manifest", "", "Manifest file (YAML or JSON) with the images to generate")
+	workers := fs.Int("jobs", runtime.GOMAXPROCS(0), "Number of images rendered concurrently")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *workers < 1 {
+		return fmt.Errorf("number of jobs must be at least 1")
+	}
+
+	if *manifest != "" {
+		otherFlags := 0
+		fs.Visit(func(f *flag.Flag) {
+			if f.Name != "manifest" && f.Name != "jobs" {
+				otherFlags++
+			}
+		})
+		if otherFlags > 0 || fs.NArg() > 0 {
+			return fmt.Errorf("--manifest cannot be combined with other options than --jobs")
+		}
+		return runManifest(*manifest, *workers)
+	}
+
+	baseSpec, err := c.prepare()
+	if err != nil {
+		return err
+	}
+	jobs, err := c.imageJobs(baseSpec)
+	if err != nil {
+		return err
+	}
+	return runImageJobs(jobs, *workers)
+}
+
+// prepare sets the defaults, checks the number of runs, and builds the spec of the images
+func (c *GenerateCommand) prepare() (*spec.Spec, error) {
+	// Set defaults if not provided
+	if len(c.sizes) == 0 {
+		c.sizes = []string{fmt.Sprintf("%dx%d", spec.DefaultWidth, spec.DefaultHeight)}
+	}
+	if c.rounds < 1 {
+		return nil, fmt.Errorf("number of runs must be at least 1")
+	}
+
+	return c.Spec()
+}
+
+// imageJobs creates the jobs of all images of the prepared spec: sizes * backgrounds * rounds.
+// The configurations are created in order, so random colors are resolved deterministically
+// with a seed, no matter how many images are rendered concurrently.
+func (c *GenerateCommand) imageJobs(baseSpec *spec.Spec) ([]imageJob, error) {
+	// With a seed, all random decisions are taken from one seeded source, in a fixed order
+	rng := baseSpec.Rand()
+
+	// Without backgrounds, the spec uses the default solid gray
+	backgroundCount := max(1, len(baseSpec.Backgrounds))
+
 	imageCount := 0
-	totalImages := len(c.sizes) * len(c.colorDefs) * c.rounds
+	totalImages := len(c.sizes) * backgroundCount * c.rounds
+	jobs := make([]imageJob, 0, totalImages)
 
 	for round := 1; round <= c.rounds; round++ {
 		for _, sizeStr := range c.sizes {
-			width, height, err := parseSize(sizeStr)
+			width, height, err := spec.ParseSize(sizeStr)
 			if err != nil {
-				return fmt.Errorf("invalid size %s: %w", sizeStr, err)
+				return nil, fmt.Errorf("invalid size %s: %w", sizeStr, err)
 			}
 
-			for _, colorDef := range c.colorDefs {
-				imageCount++
+			sizeSpec := *baseSpec
+			sizeSpec.Width = width
+			sizeSpec.Height = height
 
-				// Regenerate random colors for each round (but not for the first round)
-				actualColorDef := colorDef
-				if round > 1 && hasRandomColor(colorDef) {
-					// Re-parse the original color definition to get new random colors
-					var err error
-					actualColorDef, err = regenerateRandomColors(colorDef)
-					if err != nil {
-						return fmt.Errorf("failed to regenerate random colors: %w", err)
-					}
-				}
+			for bgIndex := 0; bgIndex < backgroundCount; bgIndex++ {
+				imageCount++
 
-				// Create configuration
-				config := generator.DefaultConfig()
-				config.Width = width
-				config.Height = height
-				config.ColorMode = actualColorDef.Mode
-				config.Colors = actualColorDef.Colors
-				config.GradientAngle = actualColorDef.Angle
-				config.TileSize = actualColorDef.TileSize
-				config.Text = c.text
-				config.TextSize = c.textSize
-				config.TextAngle = c.textAngle
-				config.Format = c.format
-				config.BorderWidth = borderWidth
-				config.BorderColor = borderColor
-
-				// Text color priority: color parameter > default text color > auto
-				if actualColorDef.TextColor != nil {
-					config.TextColor = actualColorDef.TextColor
-				} else if defaultTextColor != nil {
-					config.TextColor = defaultTextColor
+				// Create configuration; random colors are resolved anew for every image
+				config, err := sizeSpec.ConfigWithBackground(bgIndex, rng)
+				if err != nil {
+					return nil, err
 				}
 
 				// Generate filename
@@ -251,184 +297,10 @@ func (c *GenerateCommand) Execute(args []string) error {
 				filename = strings.ReplaceAll(filename, "{h}", strconv.Itoa(height))
 				filename = strings.ReplaceAll(filename, "{nr}", strconv.Itoa(imageCount))
 
-				// Generate image
-				gen := generator.NewGenerator(config)
-				img, err := gen.Generate()
-				if err != nil {
-					return fmt.Errorf("failed to generate image: %w", err)
-				}
-
-				// Write to file
-				file, err := os.Create(filename)
-				if err != nil {
-					return fmt.Errorf("failed to create file %s: %w", filename, err)
-				}
-
-				if err := gen.WriteImage(file, img); err != nil {
-					file.Close()
-					return fmt.Errorf("failed to write image to %s: %w", filename, err)
-				}
-
-				file.Close()
-				fmt.Printf("Generated: %s (%dx%d, %s)\n", filename, width, height, colorDef.Mode)
+				jobs = append(jobs, imageJob{filename: filename, config: config})
 			}
 		}
 	}
 
-	return nil
-}
-
-// parseColorParameter parses a color parameter string based on the mode
-// Format examples:
-//   - solid: "blue" or "blue:t:white"
-//   - gradient: "red,blue" or "red,blue:45" or "red,blue:45:t:white"
-//   - tiles: "red,blue" or "red,blue:10" or "red,blue:10:t:white"
-//   - noise: "red,blue,green" or "red,blue,green:10" or "red,blue,green:10:t:white"
-func parseColorParameter(param string, mode generator.ColorMode) (ColorDefinition, error) {
-	def := ColorDefinition{
-		Mode:     mode,
-		Angle:    0,
-		TileSize: 36, // default tile size
-	}
-
-	// Check for text color override at the end (:t:color)
-	textColorIdx := strings.LastIndex(param, ":t:")
-	if textColorIdx != -1 {
-		textColorStr := param[textColorIdx+3:]
-		param = param[:textColorIdx]
-
-		textCol, err := generator.ParseColor(textColorStr)
-		if err != nil {
-			return def, fmt.Errorf("invalid text color %s: %w", textColorStr, err)
-		}
-		def.TextColor = &textCol
-	}
-
-	switch mode {
-	case generator.ColorModeSolid:
-		// Format: color
-		colorStr := strings.TrimSpace(param)
-		def.ColorStrings = []string{colorStr}
-		col, err := generator.ParseColor(colorStr)
-		if err != nil {
-			return def, fmt.Errorf("invalid color %s: %w", colorStr, err)
-		}
-		def.Colors = []color.Color{col}
-
-	case generator.ColorModeGradient:
-		// Format: color1,color2[,color3...][:angle]
-		parts := strings.Split(param, ":")
-		colorsPart := parts[0]
-
-		// Parse angle if provided
-		if len(parts) > 1 {
-			angle, err := strconv.ParseFloat(parts[1], 64)
-			if err != nil {
-				return def, fmt.Errorf("invalid gradient angle %s: %w", parts[1], err)
-			}
-			def.Angle = angle
-		}
-
-		// Parse colors
-		colorStrs := strings.Split(colorsPart, ",")
-		if len(colorStrs) < 2 {
-			return def, fmt.Errorf("gradient requires at least 2 colors")
-		}
-
-		def.ColorStrings = make([]string, len(colorStrs))
-		def.Colors = make([]color.Color, 0, len(colorStrs))
-		for i, colorStr := range colorStrs {
-			colorStr = strings.TrimSpace(colorStr)
-			def.ColorStrings[i] = colorStr
-			col, err := generator.ParseColor(colorStr)
-			if err != nil {
-				return def, fmt.Errorf("invalid color %s: %w", colorStr, err)
-			}
-			def.Colors = append(def.Colors, col)
-		}
-
-	case generator.ColorModeTiled, generator.ColorModeNoise:
-		// Format: color1,color2[,color3...][:tilesize]
-		parts := strings.Split(param, ":")
-		colorsPart := parts[0]
-
-		// Parse tile size if provided
-		if len(parts) > 1 {
-			tileSize, err := strconv.Atoi(parts[1])
-			if err != nil {
-				return def, fmt.Errorf("invalid tile size %s: %w", parts[1], err)
-			}
-			def.TileSize = tileSize
-		}
-
-		// Parse colors
-		colorStrs := strings.Split(colorsPart, ",")
-		if len(colorStrs) < 2 {
-			return def, fmt.Errorf("tiles/noise requires at least 2 colors")
-		}
-
-		def.ColorStrings = make([]string, len(colorStrs))
-		def.Colors = make([]color.Color, 0, len(colorStrs))
-		for i, colorStr := range colorStrs {
-			colorStr = strings.TrimSpace(colorStr)
-			def.ColorStrings[i] = colorStr
-			col, err := generator.ParseColor(colorStr)
-			if err != nil {
-				return def, fmt.Errorf("invalid color %s: %w", colorStr, err)
-			}
-			def.Colors = append(def.Colors, col)
-		}
-	}
-
-	return def, nil
-}
-
-// parseSize parses a size string in format "WxH"
-func parseSize(sizeStr string) (width, height int, err error) {
-	parts := strings.Split(sizeStr, "x")
-	if len(parts) != 2 {
-		return 0, 0, fmt.Errorf("size must be in format WxH")
-	}
-
-	width, err = strconv.Atoi(parts[0])
-	if err != nil {
-		return 0, 0, fmt.Errorf("invalid width: %w", err)
-	}
-
-	height, err = strconv.Atoi(parts[1])
-	if err != nil {
-		return 0, 0, fmt.Errorf("invalid height: %w", err)
-	}
-
-	if width <= 0 || height <= 0 {
-		return 0, 0, fmt.Errorf("width and height must be positive")
-	}
-
-	return width, height, nil
-}
-
-// hasRandomColor checks if a color definition contains any "random" color strings
-func hasRandomColor(def ColorDefinition) bool {
-	for _, colorStr := range def.ColorStrings {
-		if strings.ToLower(strings.TrimSpace(colorStr)) == "random" {
-			return true
-		}
-	}
-	return false
-}
-
-// regenerateRandomColors regenerates random colors in a color definition
-func regenerateRandomColors(def ColorDefinition) (ColorDefinition, error) {
-	newDef := def
-	newDef.Colors = make([]color.Color, len(def.ColorStrings))
-
-	for i, colorStr := range def.ColorStrings {
-		col, err := generator.ParseColor(colorStr)
-		if err != nil {
-			return newDef, fmt.Errorf("invalid color %s: %w", colorStr, err)
-		}
-		newDef.Colors[i] = col
-	}
-
-	return newDef, nil
+	return jobs, nil
 }
@@ -0,0 +1,59 @@
+package cli
+
+import (
+	"flag"
+	"io"
+	"strings"
+	"testing"
+)
+
+// parseGenerateFlags parses the generate command line into the command's spec
+func parseGenerateFlags(args []string) (string, error) {
+	c := &GenerateCommand{}
+	fs := c.flagSet("generate")
+	fs.Init("generate", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	if err := fs.Parse(args); err != nil {
+		return "", err
+	}
+	s, err := c.Spec()
+	if err != nil {
+		return "", err
+	}
+	return s.Path(), nil
+}
+
+func TestGenerateRadialAndConicFlags(t *testing.T) {
+	tests := []struct {
+		args      []string
+		wantPath  string
+		wantError string
+	}{
+		{[]string{"-s", "400x300", "--radial", "red,blue"}, "/400x300/r:red,blue", ""},
+		{[]string{"-s", "400x300", "--radial", "red,blue:80:20,50"}, "/400x300/r:red,blue:80:20,50", ""},
+		{[]string{"-s", "400x300", "--radial", "red,blue:20,50:80:t:white"}, "/400x300/r:red,blue:80:20,50:t:white", ""},
+		{[]string{"-s", "400x300", "--conic", "red,green,blue:90"}, "/400x300/k:red,green,blue:90", ""},
+		{[]string{"-s", "400x300", "--conic", "red,blue:25,75"}, "/400x300/k:red,blue:25,75", ""},
+		{[]string{"-s", "400x300", "--radial", "red,blue", "--conic", "red,blue", "-g", "red,blue"}, "/400x300/r:red,blue/k:red,blue/g:red,blue", ""},
+		{[]string{"--radial", "red"}, "", "radial gradient requires at least 2 colors"},
+		{[]string{"--radial", "red,blue:-5"}, "", "radius must be positive"},
+		{[]string{"--conic", "red,blue:1,2,3"}, "", "invalid gradient center"},
+	}
+	for _, tt := range tests {
+		t.Run(strings.Join(tt.args, " "), func(t *testing.T) {
+			path, err := parseGenerateFlags(tt.args)
+			if tt.wantError != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantError) {
+					t.Errorf("parsing the flags = %v, want an error containing %q", err, tt.wantError)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if path != tt.wantPath {
+				t.Errorf("path = %s, want %s", path, tt.wantPath)
+			}
+		})
+	}
+}
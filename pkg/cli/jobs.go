@@ -0,0 +1,103 @@
+package cli
+
+import (
+	"fmt"
+	"os"
+	"sync"
+
+	"github.com/bylexus/imagen/pkg/generator"
+)
+
+// imageJob is a single image to render and write
+type imageJob struct {
+	filename string
+	config   *generator.ImageConfig
+}
+
+// write renders the image into its file. A partially written file is removed.
+func (j imageJob) write() error {
+	file, err := os.Create(j.filename)
+	if err != nil {
+		return fmt.Errorf("failed to create file %s: %w", j.filename, err)
+	}
+
+	if err := generator.NewGenerator(j.config).Render(file); err != nil {
+		file.Close()
+		os.Remove(j.filename)
+		return fmt.Errorf("failed to generate image %s: %w", j.filename, err)
+	}
+	if err := file.Close(); err != nil {
+		os.Remove(j.filename)
+		return fmt.Errorf("failed to write image %s: %w", j.filename, err)
+	}
+	return nil
+}
+
+// runImageJobs renders the jobs with the given number of workers. The results are reported
+// in the order of the jobs. After the first error, no new jobs are started, the running
+// jobs are finished, and the error of the earliest failed job is returned.
+func runImageJobs(jobs []imageJob, workers int) error {
+	workers = max(1, min(workers, len(jobs)))
+
+	results := make([]chan error, len(jobs))
+	for i := range results {
+		results[i] = make(chan error, 1)
+	}
+
+	stopped := make(chan struct{})
+	var stopOnce sync.Once
+	stop := func() {
+		stopOnce.Do(func() { close(stopped) })
+	}
+
+	// Hand out the jobs in order, until all jobs are taken or a job failed
+	next := make(chan int)
+	go func() {
+		defer close(next)
+		for i := range jobs {
+			select {
+			case next <- i:
+			case <-stopped:
+				return
+			}
+		}
+	}()
+
+	var wg sync.WaitGroup
+	for range workers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range next {
+				err := jobs[i].write()
+				if err != nil {
+					stop()
+				}
+				results[i] <- err
+			}
+		}()
+	}
+	defer wg.Wait()
+
+	for i, job := range jobs {
+		var err error
+		select {
+		case err = <-results[i]:
+		case <-stopped:
+			// A job failed: let the running jobs finish, then report the finished ones in order
+			wg.Wait()
+			select {
+			case err = <-results[i]:
+			default:
+				// Not started anymore
+				continue
+			}
+		}
+		if err != nil {
+			stop()
+			return err
+		}
+		fmt.Printf("Generated: %s (%dx%d, %s)\n", job.filename, job.config.Width, job.config.Height, job.config.ColorMode)
+	}
+	return nil
+}
@@ -0,0 +1,123 @@
+package cli
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/bylexus/imagen/pkg/generator"
+)
+
+// captureStdout returns what f writes to os.Stdout
+func captureStdout(t *testing.T, f func()) string {
+	t.Helper()
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	stdout := os.Stdout
+	os.Stdout = w
+	defer func() { os.Stdout = stdout }()
+
+	output := make(chan string)
+	go func() {
+		data, _ := io.ReadAll(r)
+		output <- string(data)
+	}()
+	f()
+	w.Close()
+	return <-output
+}
+
+// testJobs returns jobs writing into dir. The first image is much larger than the
+// others, so it finishes last when rendered concurrently.
+func testJobs(dir string, count int) []imageJob {
+	jobs := make([]imageJob, count)
+	for i := range jobs {
+		config := generator.DefaultConfig()
+		config.Width, config.Height = 10+i, 10
+		if i == 0 {
+			config.Width, config.Height = 1500, 1500
+		}
+		jobs[i] = imageJob{filename: filepath.Join(dir, fmt.Sprintf("image-%d.png", i)), config: config}
+	}
+	return jobs
+}
+
+// generatedLines returns the "Generated:" lines the jobs report on success
+func generatedLines(jobs []imageJob) []string {
+	lines := make([]string, len(jobs))
+	for i, job := range jobs {
+		lines[i] = fmt.Sprintf("Generated: %s (%dx%d, solid)", job.filename, job.config.Width, job.config.Height)
+	}
+	return lines
+}
+
+func TestRunImageJobsReportsInOrder(t *testing.T) {
+	defer runtime.GOMAXPROCS(runtime.GOMAXPROCS(4))
+
+	for _, workers := range []int{1, 3, 8, 100} {
+		t.Run(fmt.Sprintf("%d workers", workers), func(t *testing.T) {
+			jobs := testJobs(t.TempDir(), 8)
+			var err error
+			output := captureStdout(t, func() { err = runImageJobs(jobs, workers) })
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			want := strings.Join(generatedLines(jobs), "\n") + "\n"
+			if output != want {
+				t.Errorf("output =\n%s\nwant\n%s", output, want)
+			}
+			for _, job := range jobs {
+				if _, err := os.Stat(job.filename); err != nil {
+					t.Errorf("%s was not written: %v", job.filename, err)
+				}
+			}
+		})
+	}
+}
+
+func TestRunImageJobsStopsAtTheFirstError(t *testing.T) {
+	defer runtime.GOMAXPROCS(runtime.GOMAXPROCS(4))
+
+	for _, workers := range []int{1, 4} {
+		t.Run(fmt.Sprintf("%d workers", workers), func(t *testing.T) {
+			dir := t.TempDir()
+			jobs := testJobs(dir, 6)
+			// Jobs 3 and 5 cannot create their files
+			jobs[3].filename = filepath.Join(dir, "missing", "image-3.png")
+			jobs[5].filename = filepath.Join(dir, "missing", "image-5.png")
+
+			var err error
+			output := captureStdout(t, func() { err = runImageJobs(jobs, workers) })
+			if err == nil || !strings.Contains(err.Error(), "image-3.png") {
+				t.Errorf("runImageJobs() = %v, want the error of job 3", err)
+			}
+
+			// The jobs before the failed one are reported in order, no job after it
+			want := strings.Join(generatedLines(jobs[:3]), "\n") + "\n"
+			if output != want {
+				t.Errorf("output =\n%s\nwant\n%s", output, want)
+			}
+		})
+	}
+}
+
+func TestRunImageJobsRemovesFailedFiles(t *testing.T) {
+	job := testJobs(t.TempDir(), 1)[0]
+	job.config.Format = "unknown"
+
+	var err error
+	captureStdout(t, func() { err = runImageJobs([]imageJob{job}, 1) })
+	if err == nil {
+		t.Fatal("runImageJobs() succeeded with an unknown format")
+	}
+	if _, statErr := os.Stat(job.filename); !os.IsNotExist(statErr) {
+		t.Errorf("%s was not removed after the error %v", job.filename, err)
+	}
+}
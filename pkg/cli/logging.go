@@ -0,0 +1,35 @@
+package cli
+
+import (
+	"fmt"
+	"io"
+	"log/slog"
+	"time"
+)
+
+// newLogger creates the logger of the serve command, writing in the "human" or "json"
+// format, with the minimum level "debug", "info", "warn" or "error"
+func newLogger(w io.Writer, format, level string) (*slog.Logger, error) {
+	var minLevel slog.Level
+	if err := minLevel.UnmarshalText([]byte(level)); err != nil {
+		return nil, fmt.Errorf("invalid log level %q, must be debug, info, warn or error", level)
+	}
+
+	switch format {
+	case "human":
+		return slog.New(slog.NewTextHandler(w, &slog.HandlerOptions{Level: minLevel, ReplaceAttr: humanTime})), nil
+	case "json":
+		return slog.New(slog.NewJSONHandler(w, &slog.HandlerOptions{Level: minLevel})), nil
+	default:
+		return nil, fmt.Errorf("invalid log format %q, must be human or json", format)
+	}
+}
+
+// humanTime shortens the record time of the human format to whole seconds in the
+// local time zone, e.g. "2006-01-02T15:04:05+02:00"
+func humanTime(groups []string, a slog.Attr) slog.Attr {
+	if a.Key == slog.TimeKey && len(groups) == 0 && a.Value.Kind() == slog.KindTime {
+		a.Value = slog.StringValue(a.Value.Time().Local().Format(time.RFC3339))
+	}
+	return a
+}
@@ -0,0 +1,91 @@
+package cli
+
+import (
+	"bytes"
+	"encoding/json"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestNewLoggerHuman(t *testing.T) {
+	var buf bytes.Buffer
+	logger, err := newLogger(&buf, "human", "info")
+	if err != nil {
+		t.Fatal(err)
+	}
+	logger.With("component", "server").WithGroup("req").Info("request", "path", "/400x300/t:Hello World", "duration", 1500*time.Microsecond)
+
+	line := buf.String()
+	if !regexp.MustCompile(`^time=\d{4}-\d\d-\d\dT\d\d:\d\d:\d\d(Z|[+-]\d\d:\d\d) `).MatchString(line) {
+		t.Errorf("line %q does not start with the time in whole seconds", line)
+	}
+	want := ` level=INFO msg=request component=server req.path="/400x300/t:Hello World" req.duration=1.5ms` + "\n"
+	if !strings.HasSuffix(line, want) {
+		t.Errorf("line = %q, want it to end with %q", line, want)
+	}
+}
+
+func TestNewLoggerJSON(t *testing.T) {
+	var buf bytes.Buffer
+	logger, err := newLogger(&buf, "json", "info")
+	if err != nil {
+		t.Fatal(err)
+	}
+	logger.Info("request", "status", 200)
+
+	var record map[string]any
+	if err := json.Unmarshal(buf.Bytes(), &record); err != nil {
+		t.Fatalf("output %q is no JSON object: %v", buf.String(), err)
+	}
+	if record["level"] != "INFO" || record["msg"] != "request" || record["status"] != float64(200) {
+		t.Errorf("record = %v, want level INFO, msg request and status 200", record)
+	}
+}
+
+func TestNewLoggerLevels(t *testing.T) {
+	tests := []struct {
+		level string
+		want  []string
+	}{
+		{"debug", []string{"DEBUG", "INFO", "WARN", "ERROR"}},
+		{"info", []string{"INFO", "WARN", "ERROR"}},
+		{"WARN", []string{"WARN", "ERROR"}},
+		{"error", []string{"ERROR"}},
+	}
+	for _, tt := range tests {
+		var buf bytes.Buffer
+		logger, err := newLogger(&buf, "human", tt.level)
+		if err != nil {
+			t.Fatal(err)
+		}
+		logger.Debug("m")
+		logger.Info("m")
+		logger.Warn("m")
+		logger.Error("m")
+
+		var got []string
+		for _, m := range regexp.MustCompile(`level=(\w+)`).FindAllStringSubmatch(buf.String(), -1) {
+			got = append(got, m[1])
+		}
+		if strings.Join(got, ",") != strings.Join(tt.want, ",") {
+			t.Errorf("level %s logs %v, want %v", tt.level, got, tt.want)
+		}
+	}
+}
+
+func TestNewLoggerErrors(t *testing.T) {
+	tests := []struct {
+		format, level, want string
+	}{
+		{"human", "verbose", `invalid log level "verbose", must be debug, info, warn or error`},
+		{"text", "info", `invalid log format "text", must be human or json`},
+	}
+	for _, tt := range tests {
+		_, err := newLogger(&bytes.Buffer{}, tt.format, tt.level)
+		if err == nil || err.Error() != tt.want {
+			t.Errorf("newLogger(%q, %q) error = %v, want %q", tt.format, tt.level, err, tt.want)
+		}
+	}
+}
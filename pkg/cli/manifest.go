@@ -0,0 +1,112 @@
+package cli
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+
+	"gopkg.in/yaml.v3"
+)
+
+// manifest is the content of a manifest file. Every image entry is a mapping of generate
+// flag names (without dashes) to values, e.g.:
+//
+//	images:
+//	  - size: [400x300, 800x600]
+//	    gradient: red,blue:45
+//	    text: Hero
+//	    filename: "hero-{w}x{h}.png"
+//
+// Repeatable flags (size, color, gradient, ...) take a single value or a list.
+// JSON files are read with the same decoder, as JSON is a subset of YAML.
+type manifest struct {
+	Images []yaml.Node `yaml:"images"`
+}
+
+// runManifest validates all entries of the manifest file, then generates the images of
+// all entries with the given number of workers
+func runManifest(path string, workers int) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to read manifest: %w", err)
+	}
+
+	// Unknown top-level keys are rejected, so a misspelled "images" is reported
+	var m manifest
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(&m); err != nil && err != io.EOF {
+		return fmt.Errorf("failed to parse manifest %s: %w", path, err)
+	}
+	if len(m.Images) == 0 {
+		return fmt.Errorf("manifest %s contains no images", path)
+	}
+
+	// Validate all entries before the first image is written
+	var jobs []imageJob
+	written := make(map[string]int) // filename -> entry
+	for i, node := range m.Images {
+		entryJobs, err := manifestEntryJobs(&node)
+		if err != nil {
+			return fmt.Errorf("manifest entry %d (line %d): %w", i+1, node.Line, err)
+		}
+
+		// Images are written concurrently, so every file must be written only once
+		for _, job := range entryJobs {
+			if entry, ok := written[job.filename]; ok {
+				return fmt.Errorf("manifest entry %d (line %d): file %s is also written by entry %d", i+1, node.Line, job.filename, entry)
+			}
+			written[job.filename] = i + 1
+		}
+		jobs = append(jobs, entryJobs...)
+	}
+
+	return runImageJobs(jobs, workers)
+}
+
+// manifestEntryJobs applies the fields of an entry to a new generate command, in the
+// order of the file, and creates the jobs of its images
+func manifestEntryJobs(node *yaml.Node) ([]imageJob, error) {
+	if node.Kind != yaml.MappingNode {
+		return nil, fmt.Errorf("entry must be a mapping of options")
+	}
+
+	cmd := &GenerateCommand{}
+	fs := cmd.flagSet("generate")
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		name := node.Content[i].Value
+		value := node.Content[i+1]
+
+		if fs.Lookup(name) == nil {
+			return nil, fmt.Errorf("unknown field %s", name)
+		}
+
+		var values []string
+		switch value.Kind {
+		case yaml.ScalarNode:
+			values = []string{value.Value}
+		case yaml.SequenceNode:
+			for _, item := range value.Content {
+				if item.Kind != yaml.ScalarNode {
+					return nil, fmt.Errorf("field %s: list items must be single values", name)
+				}
+				values = append(values, item.Value)
+			}
+		default:
+			return nil, fmt.Errorf("field %s: must be a single value or a list", name)
+		}
+
+		for _, v := range values {
+			if err := fs.Set(name, v); err != nil {
+				return nil, fmt.Errorf("field %s: invalid value %q: %w", name, v, err)
+			}
+		}
+	}
+
+	s, err := cmd.prepare()
+	if err != nil {
+		return nil, err
+	}
+	return cmd.imageJobs(s)
+}
@@ -0,0 +1,118 @@
+package cli
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// writeManifest writes the manifest into a temporary directory. DIR in the content is
+// replaced with the directory, so the images are written there.
+func writeManifest(t *testing.T, name, content string) (path, dir string) {
+	t.Helper()
+	dir = t.TempDir()
+	path = filepath.Join(dir, name)
+	if err := os.WriteFile(path, []byte(strings.ReplaceAll(content, "DIR", dir)), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path, dir
+}
+
+func TestManifestValidation(t *testing.T) {
+	tests := []struct {
+		name      string
+		content   string
+		wantError string
+	}{
+		{"no images", "images: []\n", "contains no images"},
+		{"empty file", "", "contains no images"},
+		{"unknown key", "image:\n  - size: 400x300\n", "line 1: field image not found"},
+		{"unknown key next to images", "images:\n  - size: 400x300\nworkers: 2\n", "line 3: field workers not found"},
+		{"invalid yaml", "images: [\n", "failed to parse manifest"},
+		{"entry is no mapping", "images:\n  - 400x300\n", "manifest entry 1 (line 2): entry must be a mapping of options"},
+		{"unknown field", "images:\n  - size: 400x300\n    colour: red\n", "manifest entry 1 (line 2): unknown field colour"},
+		{"nested list", "images:\n  - size: [[400x300]]\n", "manifest entry 1 (line 2): field size: list items must be single values"},
+		{"mapping value", "images:\n  - size: {w: 400}\n", "manifest entry 1 (line 2): field size: must be a single value or a list"},
+		{"invalid value", "images:\n  - filename: DIR/a.png\n  - frames: many\n", `manifest entry 2 (line 3): field frames: invalid value "many"`},
+		{"invalid color", "images:\n  - color: nocolor\n    filename: DIR/a.png\n", "manifest entry 1 (line 2): field color: invalid value \"nocolor\""},
+		{"invalid size", "images:\n  - size: 400\n    filename: DIR/a.png\n", `manifest entry 1 (line 2): field size: invalid value "400": invalid size: size must be in format WxH`},
+		{"invalid size in list", "images:\n  - size: [400x300, 400]\n", `manifest entry 1 (line 2): field size: invalid value "400": invalid size`},
+		{"invalid border", "images:\n  - border: 4,nocolor\n", `manifest entry 1 (line 2): field border: invalid value "4,nocolor": invalid border color`},
+		{"invalid text color", "images:\n  - text-color: nocolor\n", `manifest entry 1 (line 2): field text-color: invalid value "nocolor": invalid text color`},
+		{"same file twice", "images:\n  - filename: DIR/a.png\n  - color: red\n    filename: DIR/a.png\n", "manifest entry 2 (line 3): file DIR/a.png is also written by entry 1"},
+		{"same default file", "images:\n  - color: red\n  - color: blue\n", "manifest entry 2 (line 3): file image.png is also written by entry 1"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path, dir := writeManifest(t, "manifest.yaml", tt.content)
+			err := runManifest(path, 2)
+			want := strings.ReplaceAll(tt.wantError, "DIR", dir)
+			if err == nil || !strings.Contains(err.Error(), want) {
+				t.Fatalf("runManifest() = %v, want an error containing %q", err, want)
+			}
+
+			// Nothing is written if any entry is invalid
+			entries, err := os.ReadDir(dir)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(entries) != 1 {
+				t.Errorf("%d files in the output directory, want only the manifest", len(entries))
+			}
+		})
+	}
+}
+
+func TestManifestMissingFile(t *testing.T) {
+	err := runManifest(filepath.Join(t.TempDir(), "missing.yaml"), 1)
+	if err == nil || !strings.Contains(err.Error(), "failed to read manifest") {
+		t.Errorf("runManifest() = %v, want a read error", err)
+	}
+}
+
+func TestManifestImages(t *testing.T) {
+	tests := []struct {
+		name      string
+		file      string
+		content   string
+		wantFiles []string
+	}{
+		{
+			name: "yaml",
+			file: "manifest.yaml",
+			content: `images:
+  - size: [40x30, 80x60]
+    gradient: red,blue:45
+    text: Hero
+    filename: "DIR/hero-{w}x{h}.png"
+  - color: [red, blue]
+    format: gif
+    filename: DIR/color.gif
+`,
+			wantFiles: []string{"color-0001.gif", "color-0002.gif", "hero-40x30-0001.png", "hero-80x60-0002.png"},
+		},
+		{
+			name:      "json",
+			file:      "manifest.json",
+			content:   `{"images": [{"size": "40x30", "noise": "red,blue:5", "seed": 1, "filename": "DIR/noise.webp", "format": "webp"}]}`,
+			wantFiles: []string{"noise.webp"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path, dir := writeManifest(t, tt.file, tt.content)
+			if err := runManifest(path, 2); err != nil {
+				t.Fatal(err)
+			}
+			for _, name := range tt.wantFiles {
+				info, err := os.Stat(filepath.Join(dir, name))
+				if err != nil {
+					t.Errorf("%s was not written: %v", name, err)
+				} else if info.Size() == 0 {
+					t.Errorf("%s is empty", name)
+				}
+			}
+		})
+	}
+}
@@ -1,33 +1,190 @@
 package cli
 
 import (
+	"context"
 	"flag"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/signal"
+	"sort"
 	"strings"
+	"syscall"
+	"time"
 
+	"github.com/bylexus/imagen/pkg/config"
 	"github.com/bylexus/imagen/pkg/server"
+	"github.com/bylexus/imagen/pkg/spec"
 )
 
 // ServeCommand handles the 'serve' command
 type ServeCommand struct {
-	listen string
+	configFile   string
+	listen       string
+	basePath     string
+	metricsPath  string
+	logFormat    string
+	logLevel     string
+	tlsCert      string
+	tlsKey       string
+	tlsSelfSign  bool
+	cacheSize    int
+	cacheEntries int
+
+	maxWidth      int
+	maxHeight     int
+	maxPixels     int64
+	maxText       int
+	maxTextSize   float64
+	maxBorder     int
+	maxFrames     int
+	maxConcurrent int
+	renderTimeout time.Duration
+
+	readTimeout     time.Duration
+	writeTimeout    time.Duration
+	idleTimeout     time.Duration
+	shutdownTimeout time.Duration
 }
 
 // Execute runs the serve command
 func (c *ServeCommand) Execute(args []string) error {
+	defaults := server.DefaultOptions()
+
 	fs := flag.NewFlagSet("serve", flag.ExitOnError)
+	fs.StringVar(&c.configFile, "config", "", "Configuration file (YAML or JSON) with server settings, image defaults and presets")
 	fs.StringVar(&c.listen, "listen", ":3000", "Listen address(es), comma-separated")
+	fs.StringVar(&c.basePath, "base-path", "", "Path prefix of all image URLs, e.g. /placeholders")
+	fs.StringVar(&c.metricsPath, "metrics-path", defaults.MetricsPath, "Path of the Prometheus metrics, empty disables them")
+	fs.StringVar(&c.logFormat, "log-format", "human", "Log format: human or json")
+	fs.StringVar(&c.logLevel, "log-level", "info", "Minimum log level: debug, info, warn or error")
+
+	// TLS
+	fs.StringVar(&c.tlsCert, "tls-cert", "", "TLS certificate file (PEM)")
+	fs.StringVar(&c.tlsKey, "tls-key", "", "TLS private key file (PEM)")
+	fs.BoolVar(&c.tlsSelfSign, "tls-self-signed", false, "Serve TLS with a self-signed certificate for localhost")
+	fs.IntVar(&c.cacheSize, "cache-size", int(defaults.CacheMaxBytes>>20), "Image cache size in MB, 0 disables the cache")
+	fs.IntVar(&c.cacheEntries, "cache-entries", defaults.CacheMaxEntries, "Maximum number of cached images, 0 disables the cache")
+
+	// Request limits
+	fs.IntVar(&c.maxWidth, "max-width", defaults.MaxWidth, "Maximum image width, 0 means unlimited")
+	fs.IntVar(&c.maxHeight, "max-height", defaults.MaxHeight, "Maximum image height, 0 means unlimited")
+	fs.Int64Var(&c.maxPixels, "max-pixels", defaults.MaxPixels, "Maximum pixel count (width x height x frames), 0 means unlimited")
+	fs.IntVar(&c.maxText, "max-text", defaults.MaxTextLength, "Maximum text length in characters, 0 means unlimited")
+	fs.Float64Var(&c.maxTextSize, "max-text-size", defaults.MaxTextSize, "Maximum text size in pt, 0 means unlimited")
+	fs.IntVar(&c.maxBorder, "max-border", defaults.MaxBorderWidth, "Maximum border width, 0 means unlimited")
+	fs.IntVar(&c.maxFrames, "max-frames", defaults.MaxFrames, "Maximum number of animation frames, 0 means unlimited")
+	fs.IntVar(&c.maxConcurrent, "max-concurrent", defaults.MaxConcurrentRenders, "Maximum number of concurrent renders, 0 means unlimited")
+	fs.DurationVar(&c.renderTimeout, "render-timeout", defaults.RenderTimeout, "Render timeout per request, 0 means no timeout")
+
+	// HTTP server timeouts
+	fs.DurationVar(&c.readTimeout, "read-timeout", defaults.ReadTimeout, "Maximum duration for reading a request, 0 means no timeout")
+	fs.DurationVar(&c.writeTimeout, "write-timeout", defaults.WriteTimeout, "Maximum duration for writing a response, 0 means no timeout")
+	fs.DurationVar(&c.idleTimeout, "idle-timeout", defaults.IdleTimeout, "Maximum idle time of keep-alive connections, 0 means no timeout")
+	fs.DurationVar(&c.shutdownTimeout, "shutdown-timeout", defaults.ShutdownTimeout, "Maximum duration of the graceful shutdown, 0 means no timeout")
 
 	if err := fs.Parse(args); err != nil {
 		return err
 	}
 
+	// The configuration file sets the flags that are not given on the command line
+	var parser *spec.Parser
+	if c.configFile != "" {
+		var err error
+		if parser, err = c.loadConfig(fs); err != nil {
+			return err
+		}
+	}
+
+	logger, err := newLogger(os.Stderr, c.logFormat, c.logLevel)
+	if err != nil {
+		return err
+	}
+	slog.SetDefault(logger)
+
 	// Parse listen addresses
 	addresses := strings.Split(c.listen, ",")
 	for i, addr := range addresses {
 		addresses[i] = strings.TrimSpace(addr)
 	}
 
-	// Create and start server
-	srv := server.NewServer(addresses)
-	return srv.Start()
+	options := defaults
+	options.BasePath = c.basePath
+	options.MetricsPath = c.metricsPath
+	options.Logger = logger
+	options.Parser = parser
+	options.TLSCertFile = c.tlsCert
+	options.TLSKeyFile = c.tlsKey
+	options.TLSSelfSigned = c.tlsSelfSign
+	options.CacheMaxBytes = int64(c.cacheSize) << 20
+	options.CacheMaxEntries = c.cacheEntries
+	options.MaxWidth = c.maxWidth
+	options.MaxHeight = c.maxHeight
+	options.MaxPixels = c.maxPixels
+	options.MaxTextLength = c.maxText
+	options.MaxTextSize = c.maxTextSize
+	options.MaxBorderWidth = c.maxBorder
+	options.MaxFrames = c.maxFrames
+	options.MaxConcurrentRenders = c.maxConcurrent
+	options.RenderTimeout = c.renderTimeout
+	options.ReadTimeout = c.readTimeout
+	options.WriteTimeout = c.writeTimeout
+	options.IdleTimeout = c.idleTimeout
+	options.ShutdownTimeout = c.shutdownTimeout
+
+	// Shut down gracefully on SIGINT / SIGTERM
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	// Create and start server, and wait until it has stopped
+	srv := server.NewServer(addresses, options)
+	if err := srv.Start(ctx); err != nil {
+		return err
+	}
+	if err := srv.Wait(); err != nil {
+		return err
+	}
+	logger.Info("Server stopped")
+	return nil
+}
+
+// loadConfig reads the configuration file, applies its server settings to the flags that
+// were not set on the command line, and returns the URL parser with the defaults and presets
+func (c *ServeCommand) loadConfig(fs *flag.FlagSet) (*spec.Parser, error) {
+	cfg, err := config.Load(c.configFile)
+	if err != nil {
+		return nil, err
+	}
+	settings, err := cfg.ServerSettings()
+	if err != nil {
+		return nil, err
+	}
+
+	given := make(map[string]bool)
+	fs.Visit(func(f *flag.Flag) {
+		given[f.Name] = true
+	})
+
+	names := make([]string, 0, len(settings))
+	for name := range settings {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if name == "config" || fs.Lookup(name) == nil {
+			return nil, fmt.Errorf("unknown server setting in %s: %s", c.configFile, name)
+		}
+		if given[name] {
+			continue
+		}
+		if err := fs.Set(name, settings[name]); err != nil {
+			return nil, fmt.Errorf("invalid server setting %s in %s: %w", name, c.configFile, err)
+		}
+	}
+
+	parser, err := cfg.Parser()
+	if err != nil {
+		return nil, fmt.Errorf("invalid configuration %s: %w", c.configFile, err)
+	}
+	return parser, nil
 }
@@ -0,0 +1,225 @@
+// Package config loads the imagen configuration file, with server settings, image
+// defaults and named presets. The file is written in YAML or JSON.
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/bylexus/imagen/pkg/spec"
+	"gopkg.in/yaml.v3"
+)
+
+// File is the content of a configuration file, e.g.:
+//
+//	server:
+//	  listen: [":3000", ":8080"]
+//	  max-width: 4096
+//	  render-timeout: 10s
+//	defaults:
+//	  size: 640x480
+//	  background: c:eeeeee
+//	  textColor: "333333"
+//	presets:
+//	  og-card: /1200x630/g:1e3a8a,9333ea:45:t:white/t:"{w}x{h}",s:48/b:4,ffffff
+type File struct {
+	// Server holds the settings of "imagen serve", named like its command line flags
+	Server map[string]any `yaml:"server" json:"server"`
+
+	// Defaults replace the built-in default values of all images
+	Defaults Defaults `yaml:"defaults" json:"defaults"`
+
+	// Presets maps preset names to URL paths (with optional query string), used with p:name
+	Presets map[string]string `yaml:"presets" json:"presets"`
+}
+
+// Defaults holds the default values of the images. Empty values keep the built-in defaults.
+// The tile size of tiles and noise is part of the background definition, e.g. "t:red,blue:20",
+// and has no separate default.
+type Defaults struct {
+	Size        string  `yaml:"size" json:"size"`             // e.g. "640x480"
+	Background  string  `yaml:"background" json:"background"` // background definition, e.g. "g:red,blue:45"
+	Text        *string `yaml:"text" json:"text"`             // an empty text disables the text
+	TextSize    float64 `yaml:"textSize" json:"textSize"`
+	TextColor   string  `yaml:"textColor" json:"textColor"`
+	TextWrap    float64 `yaml:"textWrap" json:"textWrap"`     // wrap width in percent of the image width
+	LineHeight  float64 `yaml:"lineHeight" json:"lineHeight"` // multiple of the font's line height
+	Font        string  `yaml:"font" json:"font"`             // font file path or installed font name
+	BorderWidth int     `yaml:"borderWidth" json:"borderWidth"`
+	BorderColor string  `yaml:"borderColor" json:"borderColor"` // requires a border width
+	Format      string  `yaml:"format" json:"format"`
+	Quality     int     `yaml:"quality" json:"quality"`
+	Lossless    bool    `yaml:"lossless" json:"lossless"`
+	Delay       int     `yaml:"delay" json:"delay"` // animation frame delay in ms
+}
+
+// Load reads a configuration file. Files ending in .json are read as JSON, all
+// others as YAML.
+func Load(path string) (*File, error) {
+	var f File
+	if err := DecodeFile(path, &f); err != nil {
+		return nil, err
+	}
+	return &f, nil
+}
+
+// DecodeFile decodes a YAML or JSON file (by extension) into v. Unknown fields are errors.
+func DecodeFile(path string, v any) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to read %s: %w", path, err)
+	}
+
+	if strings.EqualFold(filepath.Ext(path), ".json") {
+		dec := json.NewDecoder(bytes.NewReader(data))
+		dec.DisallowUnknownFields()
+		err = dec.Decode(v)
+	} else {
+		dec := yaml.NewDecoder(bytes.NewReader(data))
+		dec.KnownFields(true)
+		err = dec.Decode(v)
+	}
+	// An empty file is an empty configuration
+	if err != nil && !errors.Is(err, io.EOF) {
+		return fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+	return nil
+}
+
+// Parser creates the URL parser with the configured defaults and presets
+func (f *File) Parser() (*spec.Parser, error) {
+	defaults, err := f.Defaults.Spec()
+	if err != nil {
+		return nil, fmt.Errorf("invalid defaults: %w", err)
+	}
+
+	parser := spec.NewParser(defaults)
+	names := make([]string, 0, len(f.Presets))
+	for name := range f.Presets {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if err := parser.AddPreset(name, f.Presets[name]); err != nil {
+			return nil, err
+		}
+	}
+	return parser, nil
+}
+
+// Spec returns the spec with the default values
+func (d Defaults) Spec() (*spec.Spec, error) {
+	values := url.Values{}
+	if d.Size != "" {
+		values.Set("size", d.Size)
+	}
+	if d.Text != nil {
+		values.Set("text", *d.Text)
+	}
+	if d.TextSize != 0 {
+		values.Set("textSize", spec.FormatFloat(d.TextSize))
+	}
+	if d.TextColor != "" {
+		values.Set("textColor", d.TextColor)
+	}
+	if d.TextWrap != 0 {
+		values.Set("textWrap", spec.FormatFloat(d.TextWrap))
+	}
+	if d.LineHeight != 0 {
+		values.Set("lineHeight", spec.FormatFloat(d.LineHeight))
+	}
+	if d.BorderWidth != 0 {
+		border := strconv.Itoa(d.BorderWidth)
+		if d.BorderColor != "" {
+			border += "," + d.BorderColor
+		}
+		values.Set("border", border)
+	} else if d.BorderColor != "" {
+		return nil, fmt.Errorf("borderColor requires a borderWidth")
+	}
+	if d.Format != "" {
+		values.Set("format", d.Format)
+	}
+	if d.Quality != 0 {
+		values.Set("quality", strconv.Itoa(d.Quality))
+	}
+	if d.Lossless {
+		values.Set("lossless", "true")
+	}
+	if d.Delay != 0 {
+		values.Set("delay", strconv.Itoa(d.Delay))
+	}
+
+	// The background is a path segment, e.g. "g:red,blue:45"
+	path := ""
+	if d.Background != "" {
+		if strings.HasPrefix(d.Background, "p:") || !strings.Contains(d.Background, ":") {
+			return nil, fmt.Errorf("invalid background %q, must be a background definition like c:gray or g:red,blue", d.Background)
+		}
+		path = "/" + url.PathEscape(d.Background)
+	}
+	s, err := spec.ParseURL(path, values.Encode())
+	if err != nil {
+		return nil, err
+	}
+
+	// The font is set directly, as the configuration may also name a font file
+	if d.Font != "" {
+		s.Font = d.Font
+		if err := s.Validate(); err != nil {
+			return nil, err
+		}
+	}
+	return s, nil
+}
+
+// ServerSettings returns the server settings as command line flag values, by flag name.
+// Lists are joined with commas.
+func (f *File) ServerSettings() (map[string]string, error) {
+	settings := make(map[string]string, len(f.Server))
+	for name, value := range f.Server {
+		str, err := flagValue(value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid server setting %s: %w", name, err)
+		}
+		settings[name] = str
+	}
+	return settings, nil
+}
+
+// flagValue converts a YAML or JSON value to a command line flag value
+func flagValue(value any) (string, error) {
+	switch v := value.(type) {
+	case nil:
+		return "", nil
+	case string:
+		return v, nil
+	case bool:
+		return strconv.FormatBool(v), nil
+	case int:
+		return strconv.Itoa(v), nil
+	case float64:
+		return spec.FormatFloat(v), nil
+	case []any:
+		items := make([]string, len(v))
+		for i, item := range v {
+			str, err := flagValue(item)
+			if err != nil {
+				return "", err
+			}
+			items[i] = str
+		}
+		return strings.Join(items, ","), nil
+	default:
+		return "", fmt.Errorf("unsupported value %v", value)
+	}
+}
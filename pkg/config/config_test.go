@@ -0,0 +1,210 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// writeFile writes a file with the given name and content into a temporary directory
+func writeFile(t *testing.T, name, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoad(t *testing.T) {
+	tests := []struct {
+		name      string
+		file      string
+		content   string
+		wantSize  string
+		wantError string
+	}{
+		{"yaml", "imagen.yaml", "defaults:\n  size: 640x480\n", "640x480", ""},
+		{"json", "imagen.json", `{"defaults": {"size": "320x200"}}`, "320x200", ""},
+		{"empty file", "imagen.yaml", "", "", ""},
+		{"unknown yaml field", "imagen.yaml", "defaults:\n  colour: red\n", "", "field colour not found"},
+		{"unknown json field", "imagen.json", `{"default": {}}`, "", `unknown field "default"`},
+		{"invalid yaml", "imagen.yaml", "defaults: [", "", "failed to parse"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f, err := Load(writeFile(t, tt.file, tt.content))
+			if tt.wantError != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantError) {
+					t.Errorf("Load() = %v, want an error containing %q", err, tt.wantError)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Load() returned %v", err)
+			}
+			if f.Defaults.Size != tt.wantSize {
+				t.Errorf("size = %q, want %q", f.Defaults.Size, tt.wantSize)
+			}
+		})
+	}
+}
+
+func TestDefaultsSpec(t *testing.T) {
+	empty := ""
+	tests := []struct {
+		name      string
+		defaults  Defaults
+		wantPath  string
+		wantError string
+	}{
+		{"built-in defaults", Defaults{}, "/256x192", ""},
+		{"size and background", Defaults{Size: "640x480", Background: "g:red,blue:45"}, "/640x480/g:red,blue:45", ""},
+		{"no text", Defaults{Text: &empty}, `/256x192/t:""`, ""},
+		{"text settings", Defaults{TextSize: 30, TextColor: "333333", TextWrap: 80, LineHeight: 1.5}, `/256x192/t:"{w}x{h}",s:30,c:333333,w:80,l:1.5`, ""},
+		{"font", Defaults{Font: "Go Mono"}, `/256x192/t:"{w}x{h}",f:Go Mono`, ""},
+		{"border", Defaults{BorderWidth: 4, BorderColor: "white"}, "/256x192/b:4,white", ""},
+		{"border width only", Defaults{BorderWidth: 4}, "/256x192/b:4", ""},
+		{"format", Defaults{Format: "webp", Quality: 80, Lossless: true}, "/256x192/f:webp,q:80,lossless", ""},
+		{"border color only", Defaults{BorderColor: "red"}, "", "borderColor requires a borderWidth"},
+		{"preset as background", Defaults{Background: "p:card"}, "", "invalid background"},
+		{"color without prefix", Defaults{Background: "red"}, "", "invalid background"},
+		{"invalid size", Defaults{Size: "big"}, "", "invalid size"},
+		{"unknown font", Defaults{Font: "No Such Font"}, "", "unknown font"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, err := tt.defaults.Spec()
+			if tt.wantError != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantError) {
+					t.Errorf("Spec() = %v, want an error containing %q", err, tt.wantError)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Spec() returned %v", err)
+			}
+			if got := s.Path(); got != tt.wantPath {
+				t.Errorf("Spec().Path() = %s, want %s", got, tt.wantPath)
+			}
+		})
+	}
+}
+
+func TestPresetsAndOverrides(t *testing.T) {
+	f := &File{
+		Defaults: Defaults{Size: "640x480", Background: "c:eeeeee", TextColor: "333333", BorderWidth: 2},
+		Presets: map[string]string{
+			"og-card": `/1200x630/g:1e3a8a,9333ea:45:t:white/t:"{w}x{h}",s:48/b:4,ffffff`,
+			"thumb":   "/150x150/c:lightgray?text=thumb",
+		},
+	}
+	parser, err := f.Parser()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		url       string
+		wantPath  string
+		wantError string
+	}{
+		{"/", `/640x480/c:eeeeee/t:"{w}x{h}",c:333333/b:2`, ""},
+		{"/320x200", `/320x200/c:eeeeee/t:"{w}x{h}",c:333333/b:2`, ""},
+		{"/320x200/g:red,blue", `/320x200/g:red,blue/t:"{w}x{h}",c:333333/b:2`, ""},
+		{"/p:og-card", `/1200x630/g:1e3a8a,9333ea:45:t:white/t:"{w}x{h}",s:48,c:333333/b:4,ffffff`, ""},
+		{`/p:og-card/t:"Launch"`, `/1200x630/g:1e3a8a,9333ea:45:t:white/t:"Launch",s:48,c:333333/b:4,ffffff`, ""},
+		{"/800x400/p:og-card", `/800x400/g:1e3a8a,9333ea:45:t:white/t:"{w}x{h}",s:48,c:333333/b:4,ffffff`, ""},
+		{"/p:og-card/800x400", `/800x400/g:1e3a8a,9333ea:45:t:white/t:"{w}x{h}",s:48,c:333333/b:4,ffffff`, ""},
+		{"/p:og-card/800x400.jpg/c:red", `/800x400/c:red/t:"{w}x{h}",s:48,c:333333/b:4,ffffff/f:jpg`, ""},
+		{"/p:og-card/c:red/800x400", "", "size must be the first segment: 800x400"},
+		{"/320x200/800x400", "", "size must be the first segment: 800x400"},
+		{"/p:og-card/c:red", `/1200x630/c:red/t:"{w}x{h}",s:48,c:333333/b:4,ffffff`, ""},
+		{"/p:og-card?colors=red", `/1200x630/c:red/t:"{w}x{h}",s:48,c:333333/b:4,ffffff`, ""},
+		{"/p:og-card?textSize=30", `/1200x630/g:1e3a8a,9333ea:45:t:white/t:"{w}x{h}",s:30,c:333333/b:4,ffffff`, ""},
+		{"/p:thumb", `/150x150/c:lightgray/t:"thumb",c:333333/b:2`, ""},
+		{"/p:thumb?text=other", `/150x150/c:lightgray/t:"other",c:333333/b:2`, ""},
+		{"/p:thumb/b:0", `/150x150/c:lightgray/t:"thumb",c:333333`, ""},
+		{"/p:unknown", "", "unknown preset: unknown"},
+		{"/p:thumb/p:og-card", "", "conflicting values for preset"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.url, func(t *testing.T) {
+			path, query, _ := strings.Cut(tt.url, "?")
+			s, err := parser.ParseURL(path, query)
+			if tt.wantError != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantError) {
+					t.Errorf("ParseURL() = %v, want an error containing %q", err, tt.wantError)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ParseURL() returned %v", err)
+			}
+			if got := s.Path(); got != tt.wantPath {
+				t.Errorf("ParseURL().Path() = %s, want %s", got, tt.wantPath)
+			}
+		})
+	}
+}
+
+func TestInvalidPresets(t *testing.T) {
+	tests := []struct {
+		name      string
+		presets   map[string]string
+		wantError string
+	}{
+		{"invalid name", map[string]string{"og card": "/100x100"}, "invalid preset name"},
+		{"invalid definition", map[string]string{"card": "/100x100/x:1"}, "invalid preset card"},
+		{"nested preset", map[string]string{"a": "/100x100", "b": "/p:a"}, "presets cannot be nested"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := &File{Presets: tt.presets}
+			if _, err := f.Parser(); err == nil || !strings.Contains(err.Error(), tt.wantError) {
+				t.Errorf("Parser() = %v, want an error containing %q", err, tt.wantError)
+			}
+		})
+	}
+}
+
+func TestServerSettings(t *testing.T) {
+	f, err := Load(writeFile(t, "imagen.yaml", `server:
+  listen: [":3000", ":8080"]
+  max-width: 4096
+  max-text-size: 99.5
+  cache: false
+  render-timeout: 10s
+  base-path:
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	settings, err := f.ServerSettings()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := map[string]string{
+		"listen":         ":3000,:8080",
+		"max-width":      "4096",
+		"max-text-size":  "99.5",
+		"cache":          "false",
+		"render-timeout": "10s",
+		"base-path":      "",
+	}
+	for name, value := range want {
+		if settings[name] != value {
+			t.Errorf("%s = %q, want %q", name, settings[name], value)
+		}
+	}
+	if len(settings) != len(want) {
+		t.Errorf("settings = %v, want %d settings", settings, len(want))
+	}
+
+	f.Server["nested"] = map[string]any{"a": 1}
+	if _, err := f.ServerSettings(); err == nil {
+		t.Error("ServerSettings() accepted a nested value")
+	}
+}
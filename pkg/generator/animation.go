@@ -0,0 +1,332 @@
+package generator
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"image/gif"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/image/draw"
+)
+
+// IsAnimated returns true if the configuration describes more than one frame
+func (c *ImageConfig) IsAnimated() bool {
+	return c.Frames > 1
+}
+
+// SupportsAnimation returns true if the given output format can hold multiple frames
+func SupportsAnimation(format string) bool {
+	switch strings.ToLower(format) {
+	case "gif", "apng", "png":
+		return true
+	default:
+		return false
+	}
+}
+
+// Render generates the image (or all animation frames) and writes it to the given writer
+func (g *Generator) Render(w io.Writer) error {
+	_, err := g.RenderTimed(w)
+	return err
+}
+
+// RenderTimings holds the durations of the render steps
+type RenderTimings struct {
+	Render time.Duration // drawing the image or frames
+	Encode time.Duration // encoding the image file; SVG markup is written in this step only
+}
+
+// RenderTimed works like Render, and returns the duration of the drawing and encoding steps
+func (g *Generator) RenderTimed(w io.Writer) (RenderTimings, error) {
+	var timings RenderTimings
+
+	if IsVectorFormat(g.config.Format) {
+		if g.config.IsAnimated() {
+			return timings, fmt.Errorf("format %s does not support animation", g.config.Format)
+		}
+		start := time.Now()
+		err := g.WriteSVG(w)
+		timings.Encode = time.Since(start)
+		return timings, err
+	}
+
+	start := time.Now()
+	if g.config.IsAnimated() {
+		frames, err := g.GenerateFrames()
+		timings.Render = time.Since(start)
+		if err != nil {
+			return timings, err
+		}
+
+		start = time.Now()
+		err = g.WriteAnimation(w, frames)
+		timings.Encode = time.Since(start)
+		return timings, err
+	}
+
+	img, err := g.Generate()
+	timings.Render = time.Since(start)
+	if err != nil {
+		return timings, err
+	}
+
+	start = time.Now()
+	err = g.WriteImage(w, img)
+	timings.Encode = time.Since(start)
+	return timings, err
+}
+
+// GenerateFrames creates all animation frames based on the configuration
+func (g *Generator) GenerateFrames() ([]image.Image, error) {
+	count := g.config.Frames
+	if count < 1 {
+		count = 1
+	}
+
+	frames := make([]image.Image, 0, count)
+	for i := 0; i < count; i++ {
+		img, err := NewGenerator(g.frameConfig(i)).Generate()
+		if err != nil {
+			return nil, fmt.Errorf("frame %d: %w", i+1, err)
+		}
+		frames = append(frames, img)
+	}
+	return frames, nil
+}
+
+// frameConfig returns a copy of the configuration with the per-frame variations applied
+func (g *Generator) frameConfig(frame int) *ImageConfig {
+	config := *g.config
+	config.Frames = 1
+
+	// Derive a different, but reproducible seed per frame
+	if g.config.Seed != nil {
+		seed := *g.config.Seed + int64(frame)
+		config.Seed = &seed
+	}
+
+	// Rotate the gradient
+	config.GradientAngle += float64(frame) * g.config.FrameAngleStep
+
+	// Shift colors by one position per frame
+	if g.config.FrameColorShift && len(g.config.Colors) > 1 {
+		n := len(g.config.Colors)
+		config.Colors = make([]color.Color, n)
+		for i := range g.config.Colors {
+			config.Colors[i] = g.config.Colors[(i+frame)%n]
+		}
+	}
+
+	// Replace frame placeholders in text
+	config.Text = strings.ReplaceAll(config.Text, "{frame}", strconv.Itoa(frame+1))
+	config.Text = strings.ReplaceAll(config.Text, "{frames}", strconv.Itoa(g.config.Frames))
+
+	return &config
+}
+
+// WriteAnimation writes the frames as animation to the given writer in the specified format
+func (g *Generator) WriteAnimation(w io.Writer, frames []image.Image) error {
+	if len(frames) == 0 {
+		return fmt.Errorf("no frames to write")
+	}
+
+	if !SupportsAnimation(g.config.Format) {
+		return fmt.Errorf("format %s does not support animation", g.config.Format)
+	}
+
+	if strings.ToLower(g.config.Format) == "gif" {
+		return encodeGIF(w, frames, g.config.FrameDelay)
+	}
+	return encodeAPNG(w, frames, g.config.FrameDelay)
+}
+
+// encodeGIF encodes the frames as (animated) GIF, sharing one quantized palette
+func encodeGIF(w io.Writer, frames []image.Image, delay int) error {
+	palette := quantize(frames, 256)
+
+	anim := &gif.GIF{LoopCount: 0}
+	frameDelay := 0
+	if len(frames) > 1 {
+		frameDelay = gifDelay(delay)
+	}
+	for _, frame := range frames {
+		bounds := frame.Bounds()
+		paletted := image.NewPaletted(bounds, palette)
+		draw.FloydSteinberg.Draw(paletted, bounds, frame, bounds.Min)
+
+		anim.Image = append(anim.Image, paletted)
+		anim.Delay = append(anim.Delay, frameDelay)
+	}
+
+	return gif.EncodeAll(w, anim)
+}
+
+// gifDelay converts a frame delay in milliseconds to the 100ths of a second of GIF,
+// rounded to the nearest unit. The minimum is 1, as viewers replace a delay of 0
+// with their own default.
+func gifDelay(delay int) int {
+	return max((delay+5)/10, 1)
+}
+
+// colorBox is a box in RGB space used by the median cut quantizer
+type colorBox struct {
+	colors []color.RGBA
+}
+
+// quantize computes a palette of at most maxColors colors for the given images using median cut
+func quantize(images []image.Image, maxColors int) color.Palette {
+	// Collect (a sample of) the pixels of all images
+	const maxSamples = 1 << 16
+	total := 0
+	for _, img := range images {
+		total += img.Bounds().Dx() * img.Bounds().Dy()
+	}
+	step := total/maxSamples + 1
+
+	var pixels []color.RGBA
+	n := 0
+	for _, img := range images {
+		bounds := img.Bounds()
+		for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+			for x := bounds.Min.X; x < bounds.Max.X; x++ {
+				if n%step == 0 {
+					pixels = append(pixels, color.RGBAModel.Convert(img.At(x, y)).(color.RGBA))
+				}
+				n++
+			}
+		}
+	}
+
+	if len(pixels) == 0 {
+		return color.Palette{color.Black}
+	}
+
+	// Split boxes along their widest channel until we have enough boxes
+	boxes := []colorBox{{colors: pixels}}
+	for len(boxes) < maxColors {
+		idx, channel, spread := -1, 0, 0
+		for i, box := range boxes {
+			if len(box.colors) < 2 {
+				continue
+			}
+			c, s := box.widestChannel()
+			if s > spread {
+				idx, channel, spread = i, c, s
+			}
+		}
+		if idx == -1 {
+			break
+		}
+
+		lower, upper := boxes[idx].split(channel)
+		boxes[idx] = lower
+		boxes = append(boxes, upper)
+	}
+
+	palette := make(color.Palette, 0, len(boxes))
+	for _, box := range boxes {
+		palette = append(palette, box.average())
+	}
+	return palette
+}
+
+// widestChannel returns the channel (0=R, 1=G, 2=B) with the largest value range and its range
+func (b colorBox) widestChannel() (int, int) {
+	lo := [3]uint8{255, 255, 255}
+	hi := [3]uint8{0, 0, 0}
+	for _, c := range b.colors {
+		for i, v := range [3]uint8{c.R, c.G, c.B} {
+			lo[i] = minUint8(lo[i], v)
+			hi[i] = maxUint8(hi[i], v)
+		}
+	}
+
+	channel, spread := 0, 0
+	for i := 0; i < 3; i++ {
+		if s := int(hi[i]) - int(lo[i]); s > spread {
+			channel, spread = i, s
+		}
+	}
+	return channel, spread
+}
+
+// split splits the box at the median of the given channel
+func (b colorBox) split(channel int) (colorBox, colorBox) {
+	var counts [256]int
+	for _, c := range b.colors {
+		counts[channelValue(c, channel)]++
+	}
+
+	// Find the median value
+	median, seen := 0, 0
+	for v := 0; v < 256; v++ {
+		seen += counts[v]
+		if seen*2 >= len(b.colors) {
+			median = v
+			break
+		}
+	}
+
+	// If the median is the maximum value, put the median values into the upper box
+	maxValue := 0
+	for _, c := range b.colors {
+		if v := int(channelValue(c, channel)); v > maxValue {
+			maxValue = v
+		}
+	}
+	if median == maxValue {
+		median--
+	}
+
+	var lower, upper []color.RGBA
+	for _, c := range b.colors {
+		if int(channelValue(c, channel)) <= median {
+			lower = append(lower, c)
+		} else {
+			upper = append(upper, c)
+		}
+	}
+
+	return colorBox{colors: lower}, colorBox{colors: upper}
+}
+
+// average returns the average color of the box
+func (b colorBox) average() color.Color {
+	var r, g, bl int
+	for _, c := range b.colors {
+		r += int(c.R)
+		g += int(c.G)
+		bl += int(c.B)
+	}
+	n := len(b.colors)
+	return color.RGBA{R: uint8(r / n), G: uint8(g / n), B: uint8(bl / n), A: 255}
+}
+
+func channelValue(c color.RGBA, channel int) uint8 {
+	switch channel {
+	case 0:
+		return c.R
+	case 1:
+		return c.G
+	default:
+		return c.B
+	}
+}
+
+func minUint8(a, b uint8) uint8 {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+func maxUint8(a, b uint8) uint8 {
+	if a > b {
+		return a
+	}
+	return b
+}
@@ -0,0 +1,128 @@
+package generator
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/gif"
+	"slices"
+	"testing"
+)
+
+// grayRamp returns an image with one pixel of every gray level from 0 to levels-1
+func grayRamp(levels int) *image.RGBA {
+	img := image.NewRGBA(image.Rect(0, 0, levels, 1))
+	for x := 0; x < levels; x++ {
+		img.Set(x, 0, color.Gray{uint8(x)})
+	}
+	return img
+}
+
+// paletteHas reports whether the palette contains the exact color
+func paletteHas(palette color.Palette, c color.Color) bool {
+	for _, p := range palette {
+		if color.RGBAModel.Convert(p) == color.RGBAModel.Convert(c) {
+			return true
+		}
+	}
+	return false
+}
+
+func TestQuantize(t *testing.T) {
+	red := color.RGBA{255, 0, 0, 255}
+	green := color.RGBA{0, 255, 0, 255}
+	blue := color.RGBA{0, 0, 255, 255}
+
+	tests := []struct {
+		name      string
+		images    []image.Image
+		maxColors int
+		wantLen   int
+		want      []color.Color // colors that must be in the palette exactly
+	}{
+		{"no pixels", nil, 256, 1, []color.Color{color.Black}},
+		{"one color", []image.Image{solidFrame(10, 10, red)}, 256, 1, []color.Color{red}},
+		{"colors of all frames", []image.Image{solidFrame(10, 10, red), solidFrame(10, 10, green), solidFrame(10, 10, blue)}, 256, 3, []color.Color{red, green, blue}},
+		{"all levels fit", []image.Image{grayRamp(256)}, 256, 256, []color.Color{color.Gray{0}, color.Gray{127}, color.Gray{255}}},
+		{"levels reduced", []image.Image{grayRamp(256)}, 16, 16, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			palette := quantize(tt.images, tt.maxColors)
+			if len(palette) != tt.wantLen {
+				t.Errorf("quantize() returned %d colors, want %d", len(palette), tt.wantLen)
+			}
+			for _, c := range tt.want {
+				if !paletteHas(palette, c) {
+					t.Errorf("quantize() = %v, want it to contain %v", palette, c)
+				}
+			}
+		})
+	}
+}
+
+func TestQuantizeError(t *testing.T) {
+	// With 16 boxes of 16 gray levels, every level is at most 8 levels off its box average
+	palette := quantize([]image.Image{grayRamp(256)}, 16)
+	for level := 0; level < 256; level++ {
+		nearest := color.RGBAModel.Convert(palette.Convert(color.Gray{uint8(level)})).(color.RGBA)
+		if diff := int(nearest.R) - level; diff < -8 || diff > 8 {
+			t.Errorf("gray level %d is mapped to %d", level, nearest.R)
+		}
+	}
+}
+
+func TestEncodeGIF(t *testing.T) {
+	colors := []color.RGBA{{255, 0, 0, 255}, {0, 255, 0, 255}, {0, 0, 255, 255}}
+	tests := []struct {
+		name      string
+		frames    int
+		delay     int
+		wantDelay int
+		wantLoop  int
+	}{
+		{"still image", 1, 100, 0, -1}, // no loop extension and no delay
+		{"animation", 3, 120, 12, 0},
+		{"two frames", 2, 50, 5, 0},
+		{"rounded up", 2, 85, 9, 0},
+		{"rounded down", 2, 84, 8, 0},
+		{"minimum delay", 2, 4, 1, 0},
+		{"no delay", 2, 0, 1, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			frames := make([]image.Image, tt.frames)
+			for i := range frames {
+				frames[i] = solidFrame(20, 10, colors[i%len(colors)])
+			}
+
+			var buf bytes.Buffer
+			if err := encodeGIF(&buf, frames, tt.delay); err != nil {
+				t.Fatal(err)
+			}
+			anim, err := gif.DecodeAll(&buf)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if len(anim.Image) != tt.frames {
+				t.Fatalf("GIF has %d frames, want %d", len(anim.Image), tt.frames)
+			}
+			if anim.LoopCount != tt.wantLoop {
+				t.Errorf("loop count = %d, want %d", anim.LoopCount, tt.wantLoop)
+			}
+			for i, img := range anim.Image {
+				if anim.Delay[i] != tt.wantDelay {
+					t.Errorf("frame %d has delay %d, want %d", i+1, anim.Delay[i], tt.wantDelay)
+				}
+				if got := color.RGBAModel.Convert(img.At(3, 3)); got != colors[i%len(colors)] {
+					t.Errorf("frame %d has color %v, want %v", i+1, got, colors[i%len(colors)])
+				}
+				// All frames share one palette, so colors don't flicker between frames
+				if !slices.Equal(img.Palette, anim.Image[0].Palette) {
+					t.Errorf("frame %d has another palette than frame 1", i+1)
+				}
+			}
+		})
+	}
+}
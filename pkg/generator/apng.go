@@ -0,0 +1,152 @@
+package generator
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"hash/crc32"
+	"image"
+	"image/png"
+	"io"
+)
+
+// pngSignature is the magic header of every PNG file
+var pngSignature = []byte{0x89, 'P', 'N', 'G', '\r', '\n', 0x1a, '\n'}
+
+// pngChunk is a single chunk of a PNG stream
+type pngChunk struct {
+	typ  string
+	data []byte
+}
+
+// encodeAPNG encodes the frames as animated PNG (APNG)
+// Each frame is encoded with the standard PNG encoder, then the image data chunks
+// are re-packed into the APNG frame structure (acTL, fcTL, IDAT/fdAT).
+func encodeAPNG(w io.Writer, frames []image.Image, delay int) error {
+	var ihdr []byte
+	var frameData [][]byte
+
+	for i, frame := range frames {
+		var buf bytes.Buffer
+		if err := png.Encode(&buf, frame); err != nil {
+			return fmt.Errorf("failed to encode frame %d: %w", i+1, err)
+		}
+
+		chunks, err := readPNGChunks(buf.Bytes())
+		if err != nil {
+			return fmt.Errorf("failed to read frame %d: %w", i+1, err)
+		}
+
+		var data []byte
+		for _, chunk := range chunks {
+			switch chunk.typ {
+			case "IHDR":
+				if ihdr == nil {
+					ihdr = chunk.data
+				} else if !bytes.Equal(ihdr, chunk.data) {
+					return fmt.Errorf("frame %d has a different size or color type", i+1)
+				}
+			case "IDAT":
+				data = append(data, chunk.data...)
+			}
+		}
+		frameData = append(frameData, data)
+	}
+
+	bounds := frames[0].Bounds()
+
+	if _, err := w.Write(pngSignature); err != nil {
+		return err
+	}
+	if err := writePNGChunk(w, "IHDR", ihdr); err != nil {
+		return err
+	}
+
+	// Animation control: number of frames, 0 = loop forever
+	actl := make([]byte, 8)
+	binary.BigEndian.PutUint32(actl[0:4], uint32(len(frames)))
+	binary.BigEndian.PutUint32(actl[4:8], 0)
+	if err := writePNGChunk(w, "acTL", actl); err != nil {
+		return err
+	}
+
+	sequence := uint32(0)
+	for i, data := range frameData {
+		// Frame control: size, offset, delay (delay/1000 seconds), dispose and blend operation
+		fctl := make([]byte, 26)
+		binary.BigEndian.PutUint32(fctl[0:4], sequence)
+		binary.BigEndian.PutUint32(fctl[4:8], uint32(bounds.Dx()))
+		binary.BigEndian.PutUint32(fctl[8:12], uint32(bounds.Dy()))
+		binary.BigEndian.PutUint32(fctl[12:16], 0)
+		binary.BigEndian.PutUint32(fctl[16:20], 0)
+		binary.BigEndian.PutUint16(fctl[20:22], uint16(delay))
+		binary.BigEndian.PutUint16(fctl[22:24], 1000)
+		fctl[24] = 0 // APNG_DISPOSE_OP_NONE
+		fctl[25] = 0 // APNG_BLEND_OP_SOURCE
+		sequence++
+		if err := writePNGChunk(w, "fcTL", fctl); err != nil {
+			return err
+		}
+
+		// The first frame is the default image and uses IDAT, all others use fdAT
+		if i == 0 {
+			if err := writePNGChunk(w, "IDAT", data); err != nil {
+				return err
+			}
+			continue
+		}
+
+		fdat := make([]byte, 4+len(data))
+		binary.BigEndian.PutUint32(fdat[0:4], sequence)
+		copy(fdat[4:], data)
+		sequence++
+		if err := writePNGChunk(w, "fdAT", fdat); err != nil {
+			return err
+		}
+	}
+
+	return writePNGChunk(w, "IEND", nil)
+}
+
+// readPNGChunks splits an encoded PNG into its chunks
+func readPNGChunks(data []byte) ([]pngChunk, error) {
+	if !bytes.HasPrefix(data, pngSignature) {
+		return nil, fmt.Errorf("missing PNG signature")
+	}
+	data = data[len(pngSignature):]
+
+	var chunks []pngChunk
+	for len(data) >= 12 {
+		length := int(binary.BigEndian.Uint32(data[0:4]))
+		if len(data) < 12+length {
+			return nil, fmt.Errorf("truncated PNG chunk")
+		}
+		chunks = append(chunks, pngChunk{
+			typ:  string(data[4:8]),
+			data: data[8 : 8+length],
+		})
+		data = data[12+length:]
+	}
+	return chunks, nil
+}
+
+// writePNGChunk writes a single PNG chunk including length and CRC
+func writePNGChunk(w io.Writer, typ string, data []byte) error {
+	header := make([]byte, 8)
+	binary.BigEndian.PutUint32(header[0:4], uint32(len(data)))
+	copy(header[4:8], typ)
+
+	crc := crc32.NewIEEE()
+	crc.Write(header[4:8])
+	crc.Write(data)
+
+	footer := make([]byte, 4)
+	binary.BigEndian.PutUint32(footer, crc.Sum32())
+
+	for _, b := range [][]byte{header, data, footer} {
+		if _, err := w.Write(b); err != nil {
+			return err
+		}
+	}
+	return nil
+}
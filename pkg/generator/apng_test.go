@@ -0,0 +1,152 @@
+package generator
+
+import (
+	"bytes"
+	"encoding/binary"
+	"hash/crc32"
+	"image"
+	"image/color"
+	stdDraw "image/draw"
+	"image/png"
+	"slices"
+	"testing"
+)
+
+// solidFrame returns an image filled with one color
+func solidFrame(width, height int, c color.Color) *image.RGBA {
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+	stdDraw.Draw(img, img.Bounds(), &image.Uniform{c}, image.Point{}, stdDraw.Src)
+	return img
+}
+
+// stillPNG builds a PNG of a single APNG frame, from the header and the frame's image data
+func stillPNG(t *testing.T, ihdr, data []byte) image.Image {
+	t.Helper()
+	var buf bytes.Buffer
+	buf.Write(pngSignature)
+	for _, chunk := range []pngChunk{{"IHDR", ihdr}, {"IDAT", data}, {"IEND", nil}} {
+		if err := writePNGChunk(&buf, chunk.typ, chunk.data); err != nil {
+			t.Fatal(err)
+		}
+	}
+	img, err := png.Decode(&buf)
+	if err != nil {
+		t.Fatalf("frame is not a valid PNG: %v", err)
+	}
+	return img
+}
+
+func TestEncodeAPNGChunkLayout(t *testing.T) {
+	palette := []color.Color{color.RGBA{255, 0, 0, 255}, color.RGBA{0, 255, 0, 255}, color.RGBA{0, 0, 255, 255}}
+	tests := []struct {
+		name   string
+		frames int
+		delay  int
+		want   []string
+	}{
+		{"single frame", 1, 100, []string{"IHDR", "acTL", "fcTL", "IDAT", "IEND"}},
+		{"two frames", 2, 250, []string{"IHDR", "acTL", "fcTL", "IDAT", "fcTL", "fdAT", "IEND"}},
+		{"three frames", 3, 40, []string{"IHDR", "acTL", "fcTL", "IDAT", "fcTL", "fdAT", "fcTL", "fdAT", "IEND"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			frames := make([]image.Image, tt.frames)
+			for i := range frames {
+				frames[i] = solidFrame(30, 20, palette[i%len(palette)])
+			}
+
+			var buf bytes.Buffer
+			if err := encodeAPNG(&buf, frames, tt.delay); err != nil {
+				t.Fatal(err)
+			}
+			data := buf.Bytes()
+
+			// Plain PNG decoders show the first frame
+			if _, err := png.Decode(bytes.NewReader(data)); err != nil {
+				t.Fatalf("APNG is not a valid PNG: %v", err)
+			}
+
+			chunks, err := readPNGChunks(data)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var types []string
+			for _, chunk := range chunks {
+				types = append(types, chunk.typ)
+			}
+			if !slices.Equal(types, tt.want) {
+				t.Fatalf("chunks = %v, want %v", types, tt.want)
+			}
+			checkChunkCRCs(t, data)
+
+			actl := chunks[1].data
+			if n := binary.BigEndian.Uint32(actl[0:4]); n != uint32(tt.frames) {
+				t.Errorf("acTL frame count = %d, want %d", n, tt.frames)
+			}
+			if loops := binary.BigEndian.Uint32(actl[4:8]); loops != 0 {
+				t.Errorf("acTL loop count = %d, want 0 (forever)", loops)
+			}
+
+			// fcTL and fdAT share one sequence, starting at 0
+			sequence := uint32(0)
+			frame := 0
+			for _, chunk := range chunks {
+				switch chunk.typ {
+				case "fcTL":
+					if got := binary.BigEndian.Uint32(chunk.data[0:4]); got != sequence {
+						t.Errorf("fcTL sequence = %d, want %d", got, sequence)
+					}
+					width, height := binary.BigEndian.Uint32(chunk.data[4:8]), binary.BigEndian.Uint32(chunk.data[8:12])
+					if width != 30 || height != 20 {
+						t.Errorf("fcTL size = %dx%d, want 30x20", width, height)
+					}
+					num, den := binary.BigEndian.Uint16(chunk.data[20:22]), binary.BigEndian.Uint16(chunk.data[22:24])
+					if int(num) != tt.delay || den != 1000 {
+						t.Errorf("fcTL delay = %d/%d, want %d/1000", num, den, tt.delay)
+					}
+					sequence++
+				case "IDAT", "fdAT":
+					imageData := chunk.data
+					if chunk.typ == "fdAT" {
+						if got := binary.BigEndian.Uint32(chunk.data[0:4]); got != sequence {
+							t.Errorf("fdAT sequence = %d, want %d", got, sequence)
+						}
+						imageData = chunk.data[4:]
+						sequence++
+					}
+					img := stillPNG(t, chunks[0].data, imageData)
+					wantR, wantG, wantB, _ := palette[frame%len(palette)].RGBA()
+					r, g, b, _ := img.At(5, 5).RGBA()
+					if r != wantR || g != wantG || b != wantB {
+						t.Errorf("frame %d has color %v, want %v", frame+1, img.At(5, 5), palette[frame%len(palette)])
+					}
+					frame++
+				}
+			}
+		})
+	}
+}
+
+func TestEncodeAPNGRejectsDifferentFrameSizes(t *testing.T) {
+	frames := []image.Image{solidFrame(30, 20, color.White), solidFrame(20, 30, color.White)}
+	if err := encodeAPNG(&bytes.Buffer{}, frames, 100); err == nil {
+		t.Error("encodeAPNG() accepted frames of different sizes")
+	}
+}
+
+// checkChunkCRCs verifies the CRC of every chunk of an encoded PNG
+func checkChunkCRCs(t *testing.T, data []byte) {
+	t.Helper()
+	data = data[len(pngSignature):]
+	for len(data) >= 12 {
+		length := int(binary.BigEndian.Uint32(data[0:4]))
+		stored := binary.BigEndian.Uint32(data[8+length : 12+length])
+		if want := crc32.ChecksumIEEE(data[4 : 8+length]); stored != want {
+			t.Errorf("chunk %s has CRC %08x, want %08x", data[4:8], stored, want)
+		}
+		data = data[12+length:]
+	}
+	if len(data) != 0 {
+		t.Errorf("%d bytes after the last chunk", len(data))
+	}
+}
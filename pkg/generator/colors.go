@@ -11,14 +11,24 @@ import (
 // ParseColor parses a color string and returns a color.Color
 // Supports: color names (blue, red, etc.), hex codes (RRGGBB or #RRGGBB), "random"
 func ParseColor(colorStr string) (color.Color, error) {
+	return ParseColorRand(colorStr, nil)
+}
+
+// ParseColorRand parses a color string like ParseColor, but uses the given
+// random source for "random" colors. A nil source uses the global random source.
+func ParseColorRand(colorStr string, rng *rand.Rand) (color.Color, error) {
 	colorStr = strings.TrimSpace(strings.ToLower(colorStr))
 
 	// Handle "random"
 	if colorStr == "random" {
+		intn := rand.Intn
+		if rng != nil {
+			intn = rng.Intn
+		}
 		return color.RGBA{
-			R: uint8(rand.Intn(256)),
-			G: uint8(rand.Intn(256)),
-			B: uint8(rand.Intn(256)),
+			R: uint8(intn(256)),
+			G: uint8(intn(256)),
+			B: uint8(intn(256)),
 			A: 255,
 		}, nil
 	}
@@ -216,6 +226,11 @@ var namedColors = map[string]color.Color{
 	"black":          color.RGBA{0x00, 0x00, 0x00, 255},
 }
 
+// IsRandomColor returns true if the color string is the special value "random"
+func IsRandomColor(colorStr string) bool {
+	return strings.TrimSpace(strings.ToLower(colorStr)) == "random"
+}
+
 // InterpolateColor interpolates between two colors based on factor (0.0 to 1.0)
 func InterpolateColor(c1, c2 color.Color, factor float64) color.Color {
 	r1, g1, b1, a1 := c1.RGBA()
@@ -0,0 +1,496 @@
+package generator
+
+import (
+	"container/list"
+	"fmt"
+	"io/fs"
+	"math"
+	"os"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/basicfont"
+	"golang.org/x/image/font/gofont/gobold"
+	"golang.org/x/image/font/gofont/gobolditalic"
+	"golang.org/x/image/font/gofont/goitalic"
+	"golang.org/x/image/font/gofont/gomedium"
+	"golang.org/x/image/font/gofont/gomediumitalic"
+	"golang.org/x/image/font/gofont/gomono"
+	"golang.org/x/image/font/gofont/gomonobold"
+	"golang.org/x/image/font/gofont/gomonobolditalic"
+	"golang.org/x/image/font/gofont/gomonoitalic"
+	"golang.org/x/image/font/gofont/goregular"
+	"golang.org/x/image/font/gofont/gosmallcaps"
+	"golang.org/x/image/font/gofont/gosmallcapsitalic"
+	"golang.org/x/image/font/opentype"
+	"golang.org/x/image/font/sfnt"
+)
+
+// basicFontName is the name of the built-in bitmap font
+const basicFontName = "basicfont"
+
+// embeddedPrefix marks the paths of the embedded fonts, e.g. "embedded:Go-Regular.ttf"
+const embeddedPrefix = "embedded:"
+
+// defaultFontPath is the font used if no font is configured. It is embedded, so texts
+// look the same on every platform, with or without installed fonts.
+const defaultFontPath = embeddedPrefix + "Go-Regular.ttf"
+
+// embeddedFonts are the fonts of the Go font family compiled into the binary, by file name.
+// The Go fonts are licensed under the same BSD-style license as Go itself.
+var embeddedFonts = map[string][]byte{
+	"Go-Regular.ttf":          goregular.TTF,
+	"Go-Italic.ttf":           goitalic.TTF,
+	"Go-Medium.ttf":           gomedium.TTF,
+	"Go-Medium-Italic.ttf":    gomediumitalic.TTF,
+	"Go-Bold.ttf":             gobold.TTF,
+	"Go-Bold-Italic.ttf":      gobolditalic.TTF,
+	"Go-Mono.ttf":             gomono.TTF,
+	"Go-Mono-Italic.ttf":      gomonoitalic.TTF,
+	"Go-Mono-Bold.ttf":        gomonobold.TTF,
+	"Go-Mono-Bold-Italic.ttf": gomonobolditalic.TTF,
+	"Go-Smallcaps.ttf":        gosmallcaps.TTF,
+	"Go-Smallcaps-Italic.ttf": gosmallcapsitalic.TTF,
+}
+
+// FontInfo describes a font, as read from the name table of the font file
+type FontInfo struct {
+	Family   string `json:"family"`
+	Style    string `json:"style"`
+	FullName string `json:"fullName"`
+	Path     string `json:"path"`
+	Index    int    `json:"index,omitempty"`    // index of the font within a collection (.ttc)
+	Embedded bool   `json:"embedded,omitempty"` // compiled into the binary, Path starts with "embedded:"
+}
+
+// Name returns the name of the font as accepted by LookupFont, e.g. "DejaVu Sans Bold"
+func (f FontInfo) Name() string {
+	if f.FullName != "" {
+		return f.FullName
+	}
+	return strings.TrimSpace(f.Family + " " + f.Style)
+}
+
+// fontKey identifies a font within a font file
+type fontKey struct {
+	path  string
+	index int
+}
+
+// faceKey identifies a font face: a font in a size
+type faceKey struct {
+	font fontKey
+	size float64
+}
+
+// faceSizeStep is the granularity of the face sizes in pt. Text sizes are rounded to it,
+// so arbitrary sizes in URLs don't create a face pool each.
+const faceSizeStep = 0.5
+
+// maxFacePools is the number of font and size combinations kept in the cache. The least
+// recently used pool is dropped when a new one is needed.
+const maxFacePools = 64
+
+// maxFontFiles is the number of parsed font files kept in the cache. Font paths can come
+// from URLs, so the least recently used file is dropped when a new one is parsed.
+const maxFontFiles = 32
+
+// fontCache holds the parsed font files and the faces created from them. Faces are not
+// safe for concurrent use, so there is a pool of faces per font and size. Font files are
+// read and parsed without holding mu, so a slow file does not block other renders.
+var fontCache = struct {
+	mu        sync.Mutex
+	fonts     map[string]*list.Element // path -> fonts of the file
+	fontOrder *list.List               // front = most recently used
+	faces     map[faceKey]*list.Element
+	order     *list.List // front = most recently used
+}{
+	fonts:     make(map[string]*list.Element),
+	fontOrder: list.New(),
+	faces:     make(map[faceKey]*list.Element),
+	order:     list.New(),
+}
+
+// fontFileEntry is a cached, parsed font file
+type fontFileEntry struct {
+	path  string
+	fonts []*sfnt.Font
+}
+
+// facePoolEntry is a cached pool of faces
+type facePoolEntry struct {
+	key  faceKey
+	pool *sync.Pool
+}
+
+// loadFont returns the configured font in the text size, and a function that returns
+// the face to the cache after drawing. Without a configured font name, the embedded
+// default font is used.
+func (g *Generator) loadFont() (font.Face, func(), error) {
+	info, err := LookupFont(g.config.FontName)
+	if err != nil {
+		return nil, nil, err
+	}
+	if info.Path == "" {
+		return basicfont.Face7x13, func() {}, nil
+	}
+
+	key := faceKey{font: fontKey{info.Path, info.Index}, size: faceSize(g.config.TextSize)}
+	pool, err := facePool(key)
+	if err != nil {
+		return nil, nil, err
+	}
+	face := pool.Get().(font.Face)
+	return face, func() { pool.Put(face) }, nil
+}
+
+// faceSize rounds a text size to the face size step, with the step as minimum
+func faceSize(size float64) float64 {
+	return math.Max(math.Round(size/faceSizeStep)*faceSizeStep, faceSizeStep)
+}
+
+// facePool returns the pool of faces of a font in a size, and marks it as recently used
+func facePool(key faceKey) (*sync.Pool, error) {
+	fontCache.mu.Lock()
+	if elem, ok := fontCache.faces[key]; ok {
+		fontCache.order.MoveToFront(elem)
+		fontCache.mu.Unlock()
+		return elem.Value.(*facePoolEntry).pool, nil
+	}
+	fontCache.mu.Unlock()
+
+	f, err := parsedFont(key.font)
+	if err != nil {
+		return nil, err
+	}
+	// Check the face options once, so the pool can create faces without errors
+	face, err := opentype.NewFace(f, &opentype.FaceOptions{Size: key.size, DPI: 72})
+	if err != nil {
+		return nil, fmt.Errorf("failed to load font %s: %w", key.font.path, err)
+	}
+
+	pool := &sync.Pool{New: func() any {
+		face, _ := opentype.NewFace(f, &opentype.FaceOptions{Size: key.size, DPI: 72})
+		return face
+	}}
+	pool.Put(face)
+
+	fontCache.mu.Lock()
+	defer fontCache.mu.Unlock()
+	// Another render may have created the pool in the meantime
+	if elem, ok := fontCache.faces[key]; ok {
+		fontCache.order.MoveToFront(elem)
+		return elem.Value.(*facePoolEntry).pool, nil
+	}
+	fontCache.faces[key] = fontCache.order.PushFront(&facePoolEntry{key: key, pool: pool})
+
+	// Faces still in use by other renders are returned to the dropped pool, which is
+	// then garbage collected
+	for len(fontCache.faces) > maxFacePools {
+		oldest := fontCache.order.Back()
+		fontCache.order.Remove(oldest)
+		delete(fontCache.faces, oldest.Value.(*facePoolEntry).key)
+	}
+	return pool, nil
+}
+
+// parsedFont returns the font of a font file, parsing the file on first use
+func parsedFont(key fontKey) (*sfnt.Font, error) {
+	fonts, err := parsedFontFile(key.path)
+	if err != nil {
+		return nil, err
+	}
+	if key.index >= len(fonts) {
+		return nil, fmt.Errorf("font %s has no font with index %d", key.path, key.index)
+	}
+	return fonts[key.index], nil
+}
+
+// parsedFontFile returns the fonts of a font file from the cache, and marks them as
+// recently used. On a cache miss the file is parsed outside the lock; if several renders
+// miss at the same time, each parses the file and the first result is kept.
+func parsedFontFile(path string) ([]*sfnt.Font, error) {
+	fontCache.mu.Lock()
+	if elem, ok := fontCache.fonts[path]; ok {
+		fontCache.fontOrder.MoveToFront(elem)
+		fontCache.mu.Unlock()
+		return elem.Value.(*fontFileEntry).fonts, nil
+	}
+	fontCache.mu.Unlock()
+
+	fonts, err := parseFontFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	fontCache.mu.Lock()
+	defer fontCache.mu.Unlock()
+	if elem, ok := fontCache.fonts[path]; ok {
+		fontCache.fontOrder.MoveToFront(elem)
+		return elem.Value.(*fontFileEntry).fonts, nil
+	}
+	fontCache.fonts[path] = fontCache.fontOrder.PushFront(&fontFileEntry{path: path, fonts: fonts})
+
+	// Face pools keep their font, so dropping a file does not affect cached faces
+	for len(fontCache.fonts) > maxFontFiles {
+		oldest := fontCache.fontOrder.Back()
+		fontCache.fontOrder.Remove(oldest)
+		delete(fontCache.fonts, oldest.Value.(*fontFileEntry).path)
+	}
+	return fonts, nil
+}
+
+// readFontFile returns the content of a font file, or of an embedded font
+func readFontFile(path string) ([]byte, error) {
+	if name, ok := strings.CutPrefix(path, embeddedPrefix); ok {
+		data, ok := embeddedFonts[name]
+		if !ok {
+			return nil, fmt.Errorf("unknown embedded font %s", name)
+		}
+		return data, nil
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read font: %w", err)
+	}
+	return data, nil
+}
+
+// parseFontFile parses a TrueType or OpenType font file, or all fonts of a collection
+func parseFontFile(path string) ([]*sfnt.Font, error) {
+	data, err := readFontFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	// ParseCollection also accepts single fonts, as a collection of one font
+	collection, err := opentype.ParseCollection(data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse font %s: %w", path, err)
+	}
+	fonts := make([]*sfnt.Font, 0, collection.NumFonts())
+	for i := 0; i < collection.NumFonts(); i++ {
+		f, err := collection.Font(i)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse font %s: %w", path, err)
+		}
+		fonts = append(fonts, f)
+	}
+	if len(fonts) == 0 {
+		return nil, fmt.Errorf("font file %s contains no fonts", path)
+	}
+	return fonts, nil
+}
+
+// fontInfo reads the names of a font from its name table
+func fontInfo(f *sfnt.Font, path string, index int) FontInfo {
+	var buf sfnt.Buffer
+	name := func(id sfnt.NameID) string {
+		value, err := f.Name(&buf, id)
+		if err != nil {
+			return ""
+		}
+		return strings.TrimSpace(value)
+	}
+	return FontInfo{
+		Family:   name(sfnt.NameIDFamily),
+		Style:    name(sfnt.NameIDSubfamily),
+		FullName: name(sfnt.NameIDFull),
+		Path:     path,
+		Index:    index,
+		Embedded: strings.HasPrefix(path, embeddedPrefix),
+	}
+}
+
+// IsFontPath returns true if the font name refers to a font file instead of an installed
+// font, i.e. it contains a path separator or ends in a font file extension
+func IsFontPath(name string) bool {
+	return strings.ContainsAny(name, `/\`) || isFontFile(name)
+}
+
+// isFontFile returns true if the file name has the extension of a supported font file
+func isFontFile(name string) bool {
+	switch strings.ToLower(filepath.Ext(name)) {
+	case ".ttf", ".otf", ".ttc", ".otc":
+		return true
+	}
+	return false
+}
+
+// LookupFont resolves a font name: a path to a font file, or the name of an embedded or
+// installed font like "Go Bold" or "DejaVu Sans" (case insensitive). Embedded fonts take
+// precedence, installed fonts are searched in the system font directories. An empty name
+// resolves to the default font, "basicfont" to the built-in bitmap font. The Path of the
+// bitmap font is empty.
+func LookupFont(name string) (FontInfo, error) {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return defaultFont(), nil
+	}
+	if strings.EqualFold(name, basicFontName) {
+		return FontInfo{Family: basicFontName}, nil
+	}
+
+	if IsFontPath(name) {
+		return lookupFontFile(name)
+	}
+
+	wanted := normalizeFontName(name)
+	if info, ok := matchFont(EmbeddedFonts(), wanted); ok {
+		return info, nil
+	}
+	if info, ok := matchFont(SystemFonts(), wanted); ok {
+		return info, nil
+	}
+	return FontInfo{}, fmt.Errorf("unknown font %q", name)
+}
+
+// matchFont returns the font with the normalized full name or family and style name.
+// A family name selects the regular style, or the first style of the family.
+func matchFont(fonts []FontInfo, wanted string) (FontInfo, bool) {
+	for _, info := range fonts {
+		if normalizeFontName(info.FullName) == wanted || normalizeFontName(info.Family+" "+info.Style) == wanted {
+			return info, true
+		}
+	}
+	var match *FontInfo
+	for i, info := range fonts {
+		if normalizeFontName(info.Family) != wanted {
+			continue
+		}
+		if strings.EqualFold(info.Style, "Regular") {
+			return info, true
+		}
+		if match == nil {
+			match = &fonts[i]
+		}
+	}
+	if match != nil {
+		return *match, true
+	}
+	return FontInfo{}, false
+}
+
+// lookupFontFile returns the first font of a font file
+func lookupFontFile(path string) (FontInfo, error) {
+	f, err := parsedFont(fontKey{path, 0})
+	if err != nil {
+		return FontInfo{}, err
+	}
+	return fontInfo(f, path, 0), nil
+}
+
+// normalizeFontName lowercases a font name and collapses whitespace, for comparison
+func normalizeFontName(name string) string {
+	return strings.Join(strings.Fields(strings.ToLower(name)), " ")
+}
+
+// defaultFont returns the embedded default font
+var defaultFont = sync.OnceValue(func() FontInfo {
+	info, err := lookupFontFile(defaultFontPath)
+	if err != nil {
+		// The embedded fonts are always valid
+		panic(err)
+	}
+	return info
+})
+
+// FontName returns the name of the default font used for the text, e.g. "Go Regular"
+func FontName() string {
+	return defaultFont().Name()
+}
+
+// EmbeddedFonts returns the fonts compiled into the binary, sorted by name
+func EmbeddedFonts() []FontInfo {
+	return embeddedFontInfos()
+}
+
+var embeddedFontInfos = sync.OnceValue(func() []FontInfo {
+	fonts := make([]FontInfo, 0, len(embeddedFonts))
+	for name := range embeddedFonts {
+		info, err := lookupFontFile(embeddedPrefix + name)
+		if err != nil {
+			panic(err)
+		}
+		fonts = append(fonts, info)
+	}
+	sortFonts(fonts)
+	return fonts
+})
+
+// AvailableFonts returns all fonts that can be selected by name: the embedded fonts,
+// followed by the installed fonts
+func AvailableFonts() []FontInfo {
+	return append(slices.Clone(EmbeddedFonts()), SystemFonts()...)
+}
+
+// SystemFonts returns the fonts installed in the system font directories, sorted by
+// name. The directories are scanned once.
+func SystemFonts() []FontInfo {
+	return systemFonts()
+}
+
+var systemFonts = sync.OnceValue(func() []FontInfo {
+	var fonts []FontInfo
+	for _, dir := range getSystemFontDirs() {
+		// Unreadable directories and files are skipped
+		_ = filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+			if err != nil || d.IsDir() || !isFontFile(path) {
+				return nil
+			}
+			parsed, err := parseFontFile(path)
+			if err != nil {
+				return nil
+			}
+			for i, f := range parsed {
+				fonts = append(fonts, fontInfo(f, path, i))
+			}
+			return nil
+		})
+	}
+	sortFonts(fonts)
+	return fonts
+})
+
+// sortFonts sorts fonts by name, case insensitive
+func sortFonts(fonts []FontInfo) {
+	sort.SliceStable(fonts, func(i, j int) bool {
+		return strings.ToLower(fonts[i].Name()) < strings.ToLower(fonts[j].Name())
+	})
+}
+
+// getSystemFontDirs returns the directories with installed fonts, based on OS
+func getSystemFontDirs() []string {
+	home, _ := os.UserHomeDir()
+	switch runtime.GOOS {
+	case "darwin": // macOS
+		return []string{
+			"/System/Library/Fonts",
+			"/Library/Fonts",
+			filepath.Join(home, "Library", "Fonts"),
+		}
+	case "linux":
+		dataHome := os.Getenv("XDG_DATA_HOME")
+		if dataHome == "" {
+			dataHome = filepath.Join(home, ".local", "share")
+		}
+		return []string{
+			"/usr/share/fonts",
+			"/usr/local/share/fonts",
+			filepath.Join(dataHome, "fonts"),
+			filepath.Join(home, ".fonts"),
+		}
+	case "windows":
+		return []string{
+			filepath.Join(os.Getenv("WINDIR"), "Fonts"),
+			filepath.Join(os.Getenv("LOCALAPPDATA"), "Microsoft", "Windows", "Fonts"),
+		}
+	default:
+		return []string{}
+	}
+}
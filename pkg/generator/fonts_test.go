@@ -0,0 +1,295 @@
+package generator
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"golang.org/x/image/font/gofont/gobold"
+)
+
+func TestFaceSize(t *testing.T) {
+	tests := []struct {
+		size float64
+		want float64
+	}{
+		{20, 20},
+		{12.1, 12},
+		{12.3, 12.5},
+		{12.75, 13},
+		{0.1, faceSizeStep},
+	}
+	for _, tt := range tests {
+		if got := faceSize(tt.size); got != tt.want {
+			t.Errorf("faceSize(%g) = %g, want %g", tt.size, got, tt.want)
+		}
+	}
+}
+
+func TestFacePoolsAreBounded(t *testing.T) {
+	font := fontKey{defaultFontPath, 0}
+	first, err := facePool(faceKey{font: font, size: 1000})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i := range maxFacePools * 2 {
+		if _, err := facePool(faceKey{font: font, size: 1 + float64(i)*faceSizeStep}); err != nil {
+			t.Fatal(err)
+		}
+		fontCache.mu.Lock()
+		faces, order := len(fontCache.faces), fontCache.order.Len()
+		fontCache.mu.Unlock()
+		if faces > maxFacePools || order != faces {
+			t.Fatalf("%d cached face pools and %d in the LRU list, want at most %d", faces, order, maxFacePools)
+		}
+	}
+
+	// The first pool was the least recently used one, so it has been replaced
+	again, err := facePool(faceKey{font: font, size: 1000})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if again == first {
+		t.Error("the least recently used face pool was not evicted")
+	}
+}
+
+func TestFontFilesAreBounded(t *testing.T) {
+	dir := t.TempDir()
+	paths := make([]string, maxFontFiles+8)
+	for i := range paths {
+		paths[i] = filepath.Join(dir, fmt.Sprintf("Font%d.ttf", i))
+		if err := os.WriteFile(paths[i], gobold.TTF, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for _, path := range paths {
+		if _, err := LookupFont(path); err != nil {
+			t.Fatal(err)
+		}
+		fontCache.mu.Lock()
+		files, order := len(fontCache.fonts), fontCache.fontOrder.Len()
+		fontCache.mu.Unlock()
+		if files > maxFontFiles || order != files {
+			t.Fatalf("%d cached font files and %d in the LRU list, want at most %d", files, order, maxFontFiles)
+		}
+	}
+
+	// The first files were the least recently used ones, the last file is still cached
+	fontCache.mu.Lock()
+	_, firstCached := fontCache.fonts[paths[0]]
+	_, lastCached := fontCache.fonts[paths[len(paths)-1]]
+	fontCache.mu.Unlock()
+	if firstCached || !lastCached {
+		t.Errorf("first file cached = %v, last file cached = %v, want only the last file cached", firstCached, lastCached)
+	}
+}
+
+func TestConcurrentFontLookups(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "Concurrent.ttf")
+	if err := os.WriteFile(path, gobold.TTF, 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 16)
+	for i := range 16 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			config := DefaultConfig()
+			config.FontName = path
+			config.TextSize = float64(10 + i%4)
+			_, release, err := NewGenerator(config).loadFont()
+			if err != nil {
+				errs <- err
+				return
+			}
+			release()
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Error(err)
+	}
+}
+
+func TestLoadFontQuantizesSizes(t *testing.T) {
+	for _, size := range []float64{31.1, 30.9} {
+		config := DefaultConfig()
+		config.TextSize = size
+		_, release, err := NewGenerator(config).loadFont()
+		if err != nil {
+			t.Fatal(err)
+		}
+		release()
+	}
+
+	fontCache.mu.Lock()
+	defer fontCache.mu.Unlock()
+	for key := range fontCache.faces {
+		if key.size != faceSize(key.size) {
+			t.Errorf("face pool of size %g, want sizes in steps of %g", key.size, faceSizeStep)
+		}
+	}
+	if _, ok := fontCache.faces[faceKey{font: fontKey{defaultFontPath, 0}, size: 31}]; !ok {
+		t.Error("no face pool of size 31 for the text sizes 31.1 and 30.9")
+	}
+}
+
+func TestLookupFont(t *testing.T) {
+	tests := []struct {
+		name     string
+		wantName string
+		wantPath string
+	}{
+		{"", "Go Regular", defaultFontPath},
+		{"Go Regular", "Go Regular", embeddedPrefix + "Go-Regular.ttf"},
+		{"Go", "Go Regular", embeddedPrefix + "Go-Regular.ttf"},
+		{"Go Bold", "Go Bold", embeddedPrefix + "Go-Bold.ttf"},
+		{"  go   BOLD ", "Go Bold", embeddedPrefix + "Go-Bold.ttf"},
+		{"Go Mono", "Go Mono", embeddedPrefix + "Go-Mono.ttf"},
+		{"Go Mono Bold Italic", "Go Mono Bold Italic", embeddedPrefix + "Go-Mono-Bold-Italic.ttf"},
+		{"Go Smallcaps", "Go Smallcaps", embeddedPrefix + "Go-Smallcaps.ttf"},
+		{"basicfont", basicFontName, ""},
+		{"BasicFont", basicFontName, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			info, err := LookupFont(tt.name)
+			if err != nil {
+				t.Fatalf("LookupFont(%q) returned %v", tt.name, err)
+			}
+			if info.Name() != tt.wantName || info.Path != tt.wantPath {
+				t.Errorf("LookupFont(%q) = %s (%s), want %s (%s)", tt.name, info.Name(), info.Path, tt.wantName, tt.wantPath)
+			}
+			if info.Embedded != (tt.wantPath != "") {
+				t.Errorf("LookupFont(%q).Embedded = %v", tt.name, info.Embedded)
+			}
+		})
+	}
+}
+
+func TestLookupFontErrors(t *testing.T) {
+	dir := t.TempDir()
+	invalid := filepath.Join(dir, "invalid.ttf")
+	if err := os.WriteFile(invalid, []byte("no font"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name      string
+		wantError string
+	}{
+		{"No Such Font", `unknown font "No Such Font"`},
+		{"Go Extra Bold", `unknown font "Go Extra Bold"`},
+		{filepath.Join(dir, "missing.ttf"), "failed to read font"},
+		{invalid, "failed to parse font"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := LookupFont(tt.name)
+			if err == nil || !strings.Contains(err.Error(), tt.wantError) {
+				t.Errorf("LookupFont(%q) = %v, want an error containing %q", tt.name, err, tt.wantError)
+			}
+		})
+	}
+}
+
+func TestLookupFontFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "MyFont.ttf")
+	if err := os.WriteFile(path, gobold.TTF, 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	info, err := LookupFont(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Family != "Go" || info.Style != "Bold" || info.Path != path || info.Embedded {
+		t.Errorf("LookupFont(%q) = %+v, want the font Go Bold of the file", path, info)
+	}
+
+	// The file is rendered like the embedded font
+	config := DefaultConfig()
+	config.FontName = path
+	face, release, err := NewGenerator(config).loadFont()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer release()
+	if face.Metrics().Height == 0 {
+		t.Error("face of the font file has no line height")
+	}
+}
+
+func TestIsFontPath(t *testing.T) {
+	tests := []struct {
+		name string
+		want bool
+	}{
+		{"DejaVu Sans", false},
+		{"Go Bold", false},
+		{"font.ttf", true},
+		{"FONT.OTF", true},
+		{"fonts.ttc", true},
+		{"./font", true},
+		{"/usr/share/fonts/a.ttf", true},
+		{`C:\Fonts\arial`, true},
+		{"font.woff", false},
+	}
+	for _, tt := range tests {
+		if got := IsFontPath(tt.name); got != tt.want {
+			t.Errorf("IsFontPath(%q) = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestMatchFont(t *testing.T) {
+	fonts := []FontInfo{
+		{Family: "Sans", Style: "Bold", FullName: "Sans Bold", Path: "sans-bold.ttf"},
+		{Family: "Sans", Style: "Regular", FullName: "Sans", Path: "sans.ttf"},
+		{Family: "Serif", Style: "Italic", FullName: "Serif Italic", Path: "serif-italic.ttf"},
+		{Family: "Serif", Style: "Bold", FullName: "Serif Bold", Path: "serif-bold.ttf"},
+		{Family: "Mono", Style: "Book", FullName: "Mono Book Special", Path: "mono.ttf"},
+	}
+	tests := []struct {
+		wanted string
+		want   string // path, empty if there is no match
+	}{
+		{"sans bold", "sans-bold.ttf"},
+		{"sans", "sans.ttf"},          // the regular style of the family
+		{"serif", "serif-italic.ttf"}, // the first style, without a regular one
+		{"mono book special", "mono.ttf"},
+		{"mono book", "mono.ttf"}, // family and style
+		{"sans italic", ""},
+		{"san", ""},
+	}
+	for _, tt := range tests {
+		info, ok := matchFont(fonts, tt.wanted)
+		if ok != (tt.want != "") || info.Path != tt.want {
+			t.Errorf("matchFont(%q) = %s, %v, want %q", tt.wanted, info.Path, ok, tt.want)
+		}
+	}
+}
+
+func TestSystemFontsAreSorted(t *testing.T) {
+	fonts := AvailableFonts()
+	embedded := len(EmbeddedFonts())
+	if len(fonts) < embedded {
+		t.Fatalf("%d available fonts, want at least the %d embedded fonts", len(fonts), embedded)
+	}
+	for _, list := range [][]FontInfo{fonts[:embedded], fonts[embedded:]} {
+		for i := 1; i < len(list); i++ {
+			if strings.ToLower(list[i-1].Name()) > strings.ToLower(list[i].Name()) {
+				t.Errorf("%s is listed before %s", list[i-1].Name(), list[i].Name())
+			}
+		}
+	}
+}
@@ -8,29 +8,33 @@ import (
 	"image/jpeg"
 	"image/png"
 	"io"
-	"io/ioutil"
 	"math"
 	"math/rand"
-	"os"
-	"runtime"
 	"strings"
+	"time"
 
+	// Build with the "nodynamic" tag, so the WASM encoder is used instead of a
+	// shared libwebp of the system
+	"github.com/gen2brain/webp"
 	"golang.org/x/image/draw"
 	"golang.org/x/image/font"
-	"golang.org/x/image/font/basicfont"
-	"golang.org/x/image/font/opentype"
 	"golang.org/x/image/math/f64"
-	"golang.org/x/image/math/fixed"
 )
 
 // Generator handles image generation
 type Generator struct {
 	config *ImageConfig
+	rng    *rand.Rand
 }
 
 // NewGenerator creates a new image generator with the given configuration
+// If the configuration contains a seed, all random decisions are reproducible.
 func NewGenerator(config *ImageConfig) *Generator {
-	return &Generator{config: config}
+	seed := time.Now().UnixNano()
+	if config.Seed != nil {
+		seed = *config.Seed
+	}
+	return &Generator{config: config, rng: rand.New(rand.NewSource(seed))}
 }
 
 // Generate creates the image based on the configuration
@@ -50,7 +54,9 @@ func (g *Generator) Generate() (image.Image, error) {
 
 	// Draw text
 	if g.config.Text != "" {
-		g.drawText(img)
+		if err := g.drawText(img); err != nil {
+			return nil, err
+		}
 	}
 
 	return img, nil
@@ -59,15 +65,37 @@ func (g *Generator) Generate() (image.Image, error) {
 // WriteImage writes the image to the given writer in the specified format
 func (g *Generator) WriteImage(w io.Writer, img image.Image) error {
 	switch strings.ToLower(g.config.Format) {
-	case "png":
+	case "png", "apng":
 		return png.Encode(w, img)
+	case "gif":
+		return encodeGIF(w, []image.Image{img}, 0)
+	case "svg":
+		return fmt.Errorf("svg is a vector format and cannot be written from a raster image")
 	case "jpeg", "jpg":
-		return jpeg.Encode(w, img, &jpeg.Options{Quality: 90})
+		return jpeg.Encode(w, img, &jpeg.Options{Quality: g.quality()})
+	case "webp":
+		return webp.Encode(w, img, webp.Options{
+			Quality:  g.quality(),
+			Lossless: g.config.Lossless,
+			Method:   webp.DefaultMethod,
+		})
 	default:
 		return fmt.Errorf("unsupported format: %s", g.config.Format)
 	}
 }
 
+// quality returns the configured encoder quality, clamped to 1-100
+func (g *Generator) quality() int {
+	q := g.config.Quality
+	if q <= 0 {
+		return 90
+	}
+	if q > 100 {
+		return 100
+	}
+	return q
+}
+
 // drawBackground draws the background based on the color mode
 func (g *Generator) drawBackground(img *image.RGBA) error {
 	switch g.config.ColorMode {
@@ -79,6 +107,10 @@ func (g *Generator) drawBackground(img *image.RGBA) error {
 		g.drawTiledBackground(img, true)
 	case ColorModeGradient:
 		g.drawGradientBackground(img)
+	case ColorModeRadial:
+		g.drawRadialBackground(img)
+	case ColorModeConic:
+		g.drawConicBackground(img)
 	default:
 		return fmt.Errorf("unsupported color mode: %s", g.config.ColorMode)
 	}
@@ -87,8 +119,13 @@ func (g *Generator) drawBackground(img *image.RGBA) error {
 
 // drawSolidBackground fills the image with a solid color
 func (g *Generator) drawSolidBackground(img *image.RGBA) {
-	c := g.config.Colors[0]
-	stdDraw.Draw(img, img.Bounds(), &image.Uniform{c}, image.Point{}, stdDraw.Src)
+	c := rgbaBytes(g.config.Colors[0])
+	drawRows(img, func(y0, y1 int) {
+		fillPixels(pixelRow(img, y0), c)
+		for y := y0 + 1; y < y1; y++ {
+			copy(pixelRow(img, y), pixelRow(img, y0))
+		}
+	})
 }
 
 // drawTiledBackground draws a tiled/pixelated background
@@ -103,23 +140,39 @@ func (g *Generator) drawTiledBackground(img *image.RGBA, random bool) {
 		colors = []color.Color{color.Black, color.White}
 	}
 
+	// Select the tile colors first, so random colors are chosen in a fixed order
+	tilesX := (g.config.Width + tileSize - 1) / tileSize
+	tilesY := (g.config.Height + tileSize - 1) / tileSize
+	tiles := make([][4]uint8, tilesX*tilesY)
 	colorIndex := 0
-	for y := 0; y < g.config.Height; y += tileSize {
-		for x := 0; x < g.config.Width; x += tileSize {
-			// Select color
-			var c color.Color
-			if random {
-				c = colors[rand.Intn(len(colors))]
-			} else {
-				c = colors[colorIndex%len(colors)]
-				colorIndex++
+	for i := range tiles {
+		var c color.Color
+		if random {
+			c = colors[g.rng.Intn(len(colors))]
+		} else {
+			c = colors[colorIndex%len(colors)]
+			colorIndex++
+		}
+		tiles[i] = rgbaBytes(c)
+	}
+
+	// Draw the first pixel row of every tile row, and copy it to the other rows of the tiles
+	drawRows(img, func(y0, y1 int) {
+		for y := y0; y < y1; y++ {
+			row := pixelRow(img, y)
+			if y > y0 && y%tileSize != 0 {
+				copy(row, pixelRow(img, y-1))
+				continue
 			}
 
-			// Draw tile
-			rect := image.Rect(x, y, min(x+tileSize, g.config.Width), min(y+tileSize, g.config.Height))
-			stdDraw.Draw(img, rect, &image.Uniform{c}, image.Point{}, stdDraw.Src)
+			tileRow := tiles[(y/tileSize)*tilesX:]
+			for tx := 0; tx < tilesX; tx++ {
+				x0 := tx * tileSize
+				x1 := min(x0+tileSize, g.config.Width)
+				fillPixels(row[x0*4:x1*4], tileRow[tx])
+			}
 		}
-	}
+	})
 }
 
 // drawGradientBackground draws a gradient background
@@ -166,33 +219,128 @@ func (g *Generator) drawGradientBackground(img *image.RGBA) {
 	}
 
 	gradientLength := maxProj - minProj
+	stops := newGradientStops(colors)
 
 	// Draw the gradient
-	for y := 0; y < g.config.Height; y++ {
-		for x := 0; x < g.config.Width; x++ {
-			// Calculate position along gradient (0.0 to 1.0)
-			proj := float64(x)*dx + float64(y)*dy
-			t := (proj - minProj) / gradientLength
-
-			// Handle multiple colors
-			var c color.Color
-			if len(colors) == 2 {
-				c = InterpolateColor(colors[0], colors[1], t)
-			} else {
-				// Multi-color gradient
-				segment := t * float64(len(colors)-1)
-				idx := int(segment)
-				if idx >= len(colors)-1 {
-					c = colors[len(colors)-1]
-				} else {
-					localT := segment - float64(idx)
-					c = InterpolateColor(colors[idx], colors[idx+1], localT)
-				}
+	drawRows(img, func(y0, y1 int) {
+		for y := y0; y < y1; y++ {
+			row := pixelRow(img, y)
+
+			// Vertical gradients have one color per row
+			if dx == 0 {
+				proj := float64(y) * dy
+				fillPixels(row, stops.at((proj-minProj)/gradientLength))
+				continue
 			}
 
-			img.Set(x, y, c)
+			for x := 0; x < g.config.Width; x++ {
+				// Calculate position along gradient (0.0 to 1.0)
+				proj := float64(x)*dx + float64(y)*dy
+				t := (proj - minProj) / gradientLength
+
+				c := stops.at(t)
+				copy(row[x*4:x*4+4], c[:])
+			}
 		}
+	})
+}
+
+// drawRadialBackground draws a radial gradient around the gradient center
+func (g *Generator) drawRadialBackground(img *image.RGBA) {
+	colors := g.config.Colors
+	if len(colors) < 2 {
+		// Fall back to solid color
+		g.drawSolidBackground(img)
+		return
 	}
+
+	cx, cy, radius := g.radialGeometry()
+	stops := newGradientStops(colors)
+
+	drawRows(img, func(y0, y1 int) {
+		for y := y0; y < y1; y++ {
+			row := pixelRow(img, y)
+			for x := 0; x < g.config.Width; x++ {
+				// Position along the radius (0.0 at the center to 1.0 at the radius)
+				dist := math.Hypot(float64(x)-cx, float64(y)-cy)
+				t := math.Min(dist/radius, 1)
+
+				c := stops.at(t)
+				copy(row[x*4:x*4+4], c[:])
+			}
+		}
+	})
+}
+
+// drawConicBackground draws a conic gradient, sweeping clockwise around the gradient center
+func (g *Generator) drawConicBackground(img *image.RGBA) {
+	colors := g.config.Colors
+	if len(colors) < 2 {
+		// Fall back to solid color
+		g.drawSolidBackground(img)
+		return
+	}
+
+	cx, cy, _ := g.radialGeometry()
+	stops := newGradientStops(colors)
+
+	drawRows(img, func(y0, y1 int) {
+		for y := y0; y < y1; y++ {
+			row := pixelRow(img, y)
+			for x := 0; x < g.config.Width; x++ {
+				c := stops.at(g.conicPosition(float64(x)-cx, float64(y)-cy))
+				copy(row[x*4:x*4+4], c[:])
+			}
+		}
+	})
+}
+
+// radialGeometry returns the gradient center and the radial gradient radius in pixels
+func (g *Generator) radialGeometry() (cx, cy, radius float64) {
+	width := float64(g.config.Width)
+	height := float64(g.config.Height)
+	cx = width * g.config.GradientCenterX / 100
+	cy = height * g.config.GradientCenterY / 100
+
+	// The radius is relative to the distance from the center to the farthest corner
+	maxDist := 0.0
+	for _, corner := range [][2]float64{{0, 0}, {width, 0}, {0, height}, {width, height}} {
+		maxDist = math.Max(maxDist, math.Hypot(corner[0]-cx, corner[1]-cy))
+	}
+	radius = maxDist * g.config.GradientRadius / 100
+	if radius <= 0 {
+		radius = 1
+	}
+	return cx, cy, radius
+}
+
+// conicPosition returns the position (0.0 to 1.0) of the given offset from the center
+// on the conic gradient: 0 degrees points up, angles increase clockwise from the start angle
+func (g *Generator) conicPosition(dx, dy float64) float64 {
+	angle := math.Atan2(dx, -dy)*180/math.Pi - g.config.GradientAngle
+	angle = math.Mod(angle, 360)
+	if angle < 0 {
+		angle += 360
+	}
+	return angle / 360
+}
+
+// gradientColor returns the color at position t (0.0 to 1.0) of a gradient
+// with the given, evenly distributed color stops
+func gradientColor(colors []color.Color, t float64) color.Color {
+	t = clampPosition(t)
+	if len(colors) == 2 {
+		return InterpolateColor(colors[0], colors[1], t)
+	}
+
+	// Multi-color gradient
+	segment := t * float64(len(colors)-1)
+	idx := int(segment)
+	if idx >= len(colors)-1 {
+		return colors[len(colors)-1]
+	}
+	localT := segment - float64(idx)
+	return InterpolateColor(colors[idx], colors[idx+1], localT)
 }
 
 // drawBorder draws a border around the image
@@ -214,142 +362,66 @@ func (g *Generator) drawBorder(img *image.RGBA) {
 	}
 }
 
-// drawText draws text on the image
-func (g *Generator) drawText(img *image.RGBA) {
-	// Replace placeholders in text
+// textContent returns the text with the size placeholders replaced
+func (g *Generator) textContent() string {
 	text := g.config.Text
 	text = strings.ReplaceAll(text, "{w}", fmt.Sprintf("%d", g.config.Width))
 	text = strings.ReplaceAll(text, "{h}", fmt.Sprintf("%d", g.config.Height))
+	return text
+}
 
-	// Determine text color
-	var textColor color.Color = color.RGBA{255, 255, 255, 255}
+// textColor returns the configured text color, white by default
+func (g *Generator) textColor() color.Color {
 	if g.config.TextColor != nil {
-		textColor = *g.config.TextColor
+		return *g.config.TextColor
 	}
+	return color.RGBA{255, 255, 255, 255}
+}
+
+// drawText draws text on the image
+func (g *Generator) drawText(img *image.RGBA) error {
+	// Determine text color
+	textColor := g.textColor()
 
 	// Calculate inverted color for text border
 	borderColor := invertColor(textColor)
 
-	// Try to load TrueType font, fall back to basicfont
-	face := g.loadFont()
+	// Load the configured font in the text size
+	face, release, err := g.loadFont()
+	if err != nil {
+		return err
+	}
+	defer release()
+
+	// Replace placeholders in text, and split it into lines
+	layout := g.layoutText(g.textContent(), face)
 
 	// If no rotation, draw directly
 	if g.config.TextAngle == 0 {
-		g.drawTextDirect(img, text, textColor, borderColor, face)
-		return
+		drawTextBlock(img, layout, g.config.Width, g.config.Height, textColor, borderColor, face)
+		return nil
 	}
 
 	// For rotated text, draw to a temporary image and transform it
-	g.drawTextRotated(img, text, textColor, borderColor, face)
-}
-
-// drawTextDirect draws text directly without rotation
-func (g *Generator) drawTextDirect(img *image.RGBA, text string, textColor, borderColor color.Color, face font.Face) {
-	// Measure text
-	drawer := &font.Drawer{
-		Dst:  img,
-		Src:  image.NewUniform(textColor),
-		Face: face,
-	}
-
-	bounds, _ := drawer.BoundString(text)
-	textWidth := (bounds.Max.X - bounds.Min.X).Ceil()
-	textHeight := (bounds.Max.Y - bounds.Min.Y).Ceil()
-
-	// Center the text
-	x := (g.config.Width - textWidth) / 2
-	y := (g.config.Height + textHeight) / 2
-
-	basePoint := fixed.Point26_6{
-		X: fixed.I(x),
-		Y: fixed.I(y),
-	}
-
-	// Draw text border (outline) by drawing the text in 8 directions with border color
-	borderOffsets := []struct{ dx, dy int }{
-		{-1, -1}, {0, -1}, {1, -1},
-		{-1, 0}, {1, 0},
-		{-1, 1}, {0, 1}, {1, 1},
-	}
-
-	borderDrawer := &font.Drawer{
-		Dst:  img,
-		Src:  image.NewUniform(borderColor),
-		Face: face,
-	}
-
-	for _, offset := range borderOffsets {
-		borderDrawer.Dot = fixed.Point26_6{
-			X: basePoint.X + fixed.I(offset.dx),
-			Y: basePoint.Y + fixed.I(offset.dy),
-		}
-		borderDrawer.DrawString(text)
-	}
-
-	// Draw the main text on top
-	drawer.Dot = basePoint
-	drawer.DrawString(text)
+	g.drawTextRotated(img, layout, textColor, borderColor, face)
+	return nil
 }
 
 // drawTextRotated draws rotated text using image transformation
-func (g *Generator) drawTextRotated(img *image.RGBA, text string, textColor, borderColor color.Color, face font.Face) {
-	// Measure text to determine temporary image size
-	drawer := &font.Drawer{
-		Dst:  nil,
-		Src:  image.NewUniform(textColor),
-		Face: face,
-	}
-
-	bounds, _ := drawer.BoundString(text)
-	textWidth := (bounds.Max.X - bounds.Min.X).Ceil()
-	textHeight := (bounds.Max.Y - bounds.Min.Y).Ceil()
-
+func (g *Generator) drawTextRotated(img *image.RGBA, layout textLayout, textColor, borderColor color.Color, face font.Face) {
 	// Create a temporary image large enough to hold the rotated text
 	// Use a generous size to avoid clipping
 	margin := 20
-	tempSize := int(math.Max(float64(textWidth), float64(textHeight))) + margin*2
+	tempSize := int(math.Max(float64(layout.width), float64(layout.height))) + margin*2
+
+	// Text beyond the image diagonal is outside the image at every angle, so the
+	// temporary image never needs to be larger
+	diagonal := int(math.Ceil(math.Hypot(float64(g.config.Width), float64(g.config.Height))))
+	tempSize = min(tempSize, diagonal+margin*2)
 	tempImg := image.NewRGBA(image.Rect(0, 0, tempSize, tempSize))
 
 	// Draw text centered on temp image
-	tempDrawer := &font.Drawer{
-		Dst:  tempImg,
-		Src:  image.NewUniform(textColor),
-		Face: face,
-	}
-
-	// Position text at center of temp image
-	tempX := (tempSize - textWidth) / 2
-	tempY := (tempSize + textHeight) / 2
-
-	basePoint := fixed.Point26_6{
-		X: fixed.I(tempX),
-		Y: fixed.I(tempY),
-	}
-
-	// Draw text border
-	borderOffsets := []struct{ dx, dy int }{
-		{-1, -1}, {0, -1}, {1, -1},
-		{-1, 0}, {1, 0},
-		{-1, 1}, {0, 1}, {1, 1},
-	}
-
-	borderDrawer := &font.Drawer{
-		Dst:  tempImg,
-		Src:  image.NewUniform(borderColor),
-		Face: face,
-	}
-
-	for _, offset := range borderOffsets {
-		borderDrawer.Dot = fixed.Point26_6{
-			X: basePoint.X + fixed.I(offset.dx),
-			Y: basePoint.Y + fixed.I(offset.dy),
-		}
-		borderDrawer.DrawString(text)
-	}
-
-	// Draw main text
-	tempDrawer.Dot = basePoint
-	tempDrawer.DrawString(text)
+	drawTextBlock(tempImg, layout, tempSize, tempSize, textColor, borderColor, face)
 
 	// Create rotation transformation matrix
 	angleRad := g.config.TextAngle * math.Pi / 180.0
@@ -391,96 +463,6 @@ func invertColor(c color.Color) color.Color {
 	}
 }
 
-// loadFont attempts to load a TrueType font from the system, falls back to basicfont
-func (g *Generator) loadFont() font.Face {
-	// Try to load system TrueType font
-	fontPaths := getSystemFontPaths()
-
-	for _, fontPath := range fontPaths {
-		if face := tryLoadTTF(fontPath, g.config.TextSize); face != nil {
-			return face
-		}
-	}
-
-	// Fall back to basicfont
-	return basicfont.Face7x13
-}
-
-// getSystemFontPaths returns common system font paths based on OS
-func getSystemFontPaths() []string {
-	switch runtime.GOOS {
-	case "darwin": // macOS
-		return []string{
-			"/System/Library/Fonts/Helvetica.ttc",
-			"/System/Library/Fonts/SFNSText.ttf",
-			"/System/Library/Fonts/SFNS.ttf",
-			"/Library/Fonts/Arial.ttf",
-			"/System/Library/Fonts/Supplemental/Arial.ttf",
-		}
-	case "linux":
-		return []string{
-			"/usr/share/fonts/truetype/dejavu/DejaVuSans.ttf",
-			"/usr/share/fonts/truetype/liberation/LiberationSans-Regular.ttf",
-			"/usr/share/fonts/TTF/DejaVuSans.ttf",
-			"/usr/share/fonts/truetype/freefont/FreeSans.ttf",
-		}
-	case "windows":
-		return []string{
-			"C:\\Windows\\Fonts\\arial.ttf",
-			"C:\\Windows\\Fonts\\calibri.ttf",
-		}
-	default:
-		return []string{}
-	}
-}
-
-// tryLoadTTF attempts to load a TrueType font from the given path
-func tryLoadTTF(fontPath string, size float64) font.Face {
-	// Check if file exists
-	if _, err := os.Stat(fontPath); os.IsNotExist(err) {
-		return nil
-	}
-
-	// Read font file
-	fontData, err := ioutil.ReadFile(fontPath)
-	if err != nil {
-		return nil
-	}
-
-	// Parse font
-	// Try as TrueType collection first (for .ttc files)
-	f, err := opentype.ParseCollection(fontData)
-	if err == nil && f.NumFonts() > 0 {
-		// Use first font in collection
-		font, err := f.Font(0)
-		if err == nil {
-			face, err := opentype.NewFace(font, &opentype.FaceOptions{
-				Size: size,
-				DPI:  72,
-			})
-			if err == nil {
-				return face
-			}
-		}
-	}
-
-	// Try as single TrueType font
-	ttf, err := opentype.Parse(fontData)
-	if err != nil {
-		return nil
-	}
-
-	face, err := opentype.NewFace(ttf, &opentype.FaceOptions{
-		Size: size,
-		DPI:  72,
-	})
-	if err != nil {
-		return nil
-	}
-
-	return face
-}
-
 func min(a, b int) int {
 	if a < b {
 		return a
@@ -0,0 +1,126 @@
+package generator
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"testing"
+
+	"golang.org/x/image/webp"
+)
+
+// webpTestImage renders a gradient with text, whose edges the lossy encoder blurs
+func webpTestImage(t *testing.T) image.Image {
+	t.Helper()
+	config := DefaultConfig()
+	config.Width, config.Height = 160, 120
+	config.ColorMode = ColorModeGradient
+	config.Colors = []color.Color{color.RGBA{200, 30, 30, 255}, color.RGBA{20, 90, 220, 255}}
+	config.GradientAngle = 45
+	config.TextSize = 24
+	img, err := NewGenerator(config).Generate()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return img
+}
+
+// encodeWebP writes the image as WebP with the given options
+func encodeWebP(t *testing.T, img image.Image, quality int, lossless bool) []byte {
+	t.Helper()
+	config := DefaultConfig()
+	config.Format = "webp"
+	config.Quality = quality
+	config.Lossless = lossless
+	var buf bytes.Buffer
+	if err := NewGenerator(config).WriteImage(&buf, img); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+// meanDifference returns the mean absolute difference of the RGB channels of two images
+func meanDifference(a, b image.Image) float64 {
+	var sum, count float64
+	bounds := a.Bounds()
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			r1, g1, b1, _ := a.At(x, y).RGBA()
+			r2, g2, b2, _ := b.At(x, y).RGBA()
+			for _, d := range []int{int(r1>>8) - int(r2>>8), int(g1>>8) - int(g2>>8), int(b1>>8) - int(b2>>8)} {
+				sum += float64(max(d, -d))
+				count++
+			}
+		}
+	}
+	return sum / count
+}
+
+func TestWriteImageWebP(t *testing.T) {
+	img := webpTestImage(t)
+
+	tests := []struct {
+		name      string
+		quality   int
+		lossless  bool
+		wantChunk string  // VP8 (lossy) or VP8L (lossless) bitstream
+		maxDiff   float64 // maximum mean channel difference after decoding
+	}{
+		{"lossless", 90, true, "VP8L", 0},
+		{"lossless ignores quality", 10, true, "VP8L", 0},
+		{"high quality", 100, false, "VP8 ", 7},
+		{"default quality", 90, false, "VP8 ", 7},
+		{"low quality", 10, false, "VP8 ", 10},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data := encodeWebP(t, img, tt.quality, tt.lossless)
+			if len(data) < 16 || string(data[:4]) != "RIFF" || string(data[8:12]) != "WEBP" {
+				t.Fatalf("output is no WebP file: % x", data[:min(len(data), 16)])
+			}
+			if chunk := string(data[12:16]); chunk != tt.wantChunk {
+				t.Errorf("bitstream chunk = %q, want %q", chunk, tt.wantChunk)
+			}
+
+			decoded, err := webp.Decode(bytes.NewReader(data))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if decoded.Bounds() != img.Bounds() {
+				t.Fatalf("decoded bounds = %v, want %v", decoded.Bounds(), img.Bounds())
+			}
+			if diff := meanDifference(img, decoded); diff > tt.maxDiff {
+				t.Errorf("mean difference = %.2f, want at most %.2f", diff, tt.maxDiff)
+			}
+		})
+	}
+}
+
+func TestWriteImageWebPQuality(t *testing.T) {
+	img := webpTestImage(t)
+	low := encodeWebP(t, img, 10, false)
+	high := encodeWebP(t, img, 95, false)
+	if len(low) >= len(high) {
+		t.Errorf("quality 10 gives %d bytes, quality 95 %d bytes, want fewer bytes for the lower quality", len(low), len(high))
+	}
+
+	decodedLow, err := webp.Decode(bytes.NewReader(low))
+	if err != nil {
+		t.Fatal(err)
+	}
+	decodedHigh, err := webp.Decode(bytes.NewReader(high))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if dl, dh := meanDifference(img, decodedLow), meanDifference(img, decodedHigh); dl <= dh {
+		t.Errorf("mean difference at quality 10 = %.2f, at quality 95 = %.2f, want more loss at the lower quality", dl, dh)
+	}
+
+	// Out of range qualities are clamped: 0 means the default 90, above 100 means 100
+	if !bytes.Equal(encodeWebP(t, img, 0, false), encodeWebP(t, img, 90, false)) {
+		t.Error("quality 0 differs from the default quality 90")
+	}
+	if !bytes.Equal(encodeWebP(t, img, 150, false), encodeWebP(t, img, 100, false)) {
+		t.Error("quality 150 differs from quality 100")
+	}
+}
@@ -0,0 +1,121 @@
+package generator
+
+import (
+	"image"
+	"image/color"
+	"math"
+	"runtime"
+	"sync"
+)
+
+// minParallelPixels is the image size from which the backgrounds are drawn by
+// several goroutines. Smaller images are faster to draw in one go.
+const minParallelPixels = 256 * 256
+
+// drawRows calls draw for bands of rows [y0, y1) of the image. Large images are split
+// into one band per CPU, drawn concurrently. draw must only write to its own rows.
+// A panic in a band is re-raised in the calling goroutine, so callers can recover it.
+func drawRows(img *image.RGBA, draw func(y0, y1 int)) {
+	height := img.Rect.Dy()
+	bands := min(runtime.GOMAXPROCS(0), height)
+	if bands < 2 || img.Rect.Dx()*height < minParallelPixels {
+		draw(0, height)
+		return
+	}
+
+	var wg sync.WaitGroup
+	panics := make([]any, bands)
+	for i := 0; i < bands; i++ {
+		y0 := height * i / bands
+		y1 := height * (i + 1) / bands
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer func() {
+				panics[i] = recover()
+			}()
+			draw(y0, y1)
+		}()
+	}
+	wg.Wait()
+
+	for _, p := range panics {
+		if p != nil {
+			panic(p)
+		}
+	}
+}
+
+// pixelRow returns the pixel bytes of row y
+func pixelRow(img *image.RGBA, y int) []byte {
+	offset := img.PixOffset(img.Rect.Min.X, img.Rect.Min.Y+y)
+	return img.Pix[offset : offset+img.Rect.Dx()*4]
+}
+
+// rgbaBytes returns the 8-bit premultiplied RGBA values of a color, as stored in RGBA.Pix
+func rgbaBytes(c color.Color) [4]uint8 {
+	r, g, b, a := c.RGBA()
+	return [4]uint8{uint8(r >> 8), uint8(g >> 8), uint8(b >> 8), uint8(a >> 8)}
+}
+
+// fillPixels fills the pixel bytes with a color
+func fillPixels(pix []byte, c [4]uint8) {
+	if len(pix) < 4 {
+		return
+	}
+	copy(pix, c[:])
+	// Double the filled part on every copy
+	for filled := 4; filled < len(pix); filled *= 2 {
+		copy(pix[filled:], pix[:filled])
+	}
+}
+
+// gradientStops holds the color stops of a gradient as 8-bit premultiplied values,
+// so colors can be interpolated without converting color.Color values per pixel
+type gradientStops [][4]int
+
+// newGradientStops converts the evenly distributed colors of a gradient
+func newGradientStops(colors []color.Color) gradientStops {
+	stops := make(gradientStops, len(colors))
+	for i, c := range colors {
+		r, g, b, a := c.RGBA()
+		stops[i] = [4]int{int(r >> 8), int(g >> 8), int(b >> 8), int(a >> 8)}
+	}
+	return stops
+}
+
+// at returns the color at position t (0.0 to 1.0), with the same results as gradientColor.
+// Positions outside the range are clamped, NaN is treated as 0.
+func (s gradientStops) at(t float64) [4]uint8 {
+	t = clampPosition(t)
+	if len(s) == 2 {
+		return interpolateStops(s[0], s[1], t)
+	}
+
+	// Multi-color gradient
+	segment := t * float64(len(s)-1)
+	idx := int(segment)
+	if idx >= len(s)-1 {
+		last := s[len(s)-1]
+		return [4]uint8{uint8(last[0]), uint8(last[1]), uint8(last[2]), uint8(last[3])}
+	}
+	return interpolateStops(s[idx], s[idx+1], segment-float64(idx))
+}
+
+// clampPosition limits a gradient position to the range 0.0 to 1.0, with NaN as 0
+func clampPosition(t float64) float64 {
+	if !(t > 0) { // also true for NaN
+		return 0
+	}
+	return math.Min(t, 1)
+}
+
+// interpolateStops interpolates between two stops, with the same results as InterpolateColor
+func interpolateStops(c1, c2 [4]int, factor float64) [4]uint8 {
+	return [4]uint8{
+		uint8(float64(c1[0]) + factor*float64(c2[0]-c1[0])),
+		uint8(float64(c1[1]) + factor*float64(c2[1]-c1[1])),
+		uint8(float64(c1[2]) + factor*float64(c2[2]-c1[2])),
+		uint8(float64(c1[3]) + factor*float64(c2[3]-c1[3])),
+	}
+}
@@ -0,0 +1,333 @@
+package generator
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/color"
+	stdDraw "image/draw"
+	"math"
+	"runtime"
+	"testing"
+)
+
+// referenceBackground draws the background with the former per-pixel renderers, which
+// set every pixel with img.Set and an interpolated color.Color
+func referenceBackground(g *Generator, img *image.RGBA) {
+	colors := g.config.Colors
+	switch g.config.ColorMode {
+	case ColorModeSolid:
+		stdDraw.Draw(img, img.Bounds(), &image.Uniform{colors[0]}, image.Point{}, stdDraw.Src)
+
+	case ColorModeTiled, ColorModeNoise:
+		tileSize := g.config.TileSize
+		colorIndex := 0
+		for y := 0; y < g.config.Height; y += tileSize {
+			for x := 0; x < g.config.Width; x += tileSize {
+				var c color.Color
+				if g.config.ColorMode == ColorModeNoise {
+					c = colors[g.rng.Intn(len(colors))]
+				} else {
+					c = colors[colorIndex%len(colors)]
+					colorIndex++
+				}
+				rect := image.Rect(x, y, min(x+tileSize, g.config.Width), min(y+tileSize, g.config.Height))
+				stdDraw.Draw(img, rect, &image.Uniform{c}, image.Point{}, stdDraw.Src)
+			}
+		}
+
+	case ColorModeGradient:
+		angleRad := g.config.GradientAngle * math.Pi / 180.0
+		dx, dy := math.Sin(angleRad), math.Cos(angleRad)
+		width, height := float64(g.config.Width), float64(g.config.Height)
+		minProj, maxProj := 0.0, 0.0
+		for i, corner := range [][2]float64{{0, 0}, {width, 0}, {0, height}, {width, height}} {
+			proj := corner[0]*dx + corner[1]*dy
+			if i == 0 {
+				minProj, maxProj = proj, proj
+			} else {
+				minProj, maxProj = math.Min(minProj, proj), math.Max(maxProj, proj)
+			}
+		}
+		for y := 0; y < g.config.Height; y++ {
+			for x := 0; x < g.config.Width; x++ {
+				proj := float64(x)*dx + float64(y)*dy
+				img.Set(x, y, gradientColor(colors, (proj-minProj)/(maxProj-minProj)))
+			}
+		}
+
+	case ColorModeRadial:
+		cx, cy, radius := g.radialGeometry()
+		for y := 0; y < g.config.Height; y++ {
+			for x := 0; x < g.config.Width; x++ {
+				dist := math.Hypot(float64(x)-cx, float64(y)-cy)
+				img.Set(x, y, gradientColor(colors, math.Min(dist/radius, 1)))
+			}
+		}
+
+	case ColorModeConic:
+		cx, cy, _ := g.radialGeometry()
+		for y := 0; y < g.config.Height; y++ {
+			for x := 0; x < g.config.Width; x++ {
+				img.Set(x, y, gradientColor(colors, g.conicPosition(float64(x)-cx, float64(y)-cy)))
+			}
+		}
+	}
+}
+
+// testConfig returns a seeded configuration of the given color mode and size
+func testConfig(mode ColorMode, width, height int) *ImageConfig {
+	config := DefaultConfig()
+	config.Width = width
+	config.Height = height
+	config.ColorMode = mode
+	config.Colors = []color.Color{
+		color.RGBA{0xff, 0x00, 0x00, 0xff},
+		color.RGBA{0x00, 0x80, 0xff, 0xff},
+		color.RGBA{0x20, 0xc0, 0x40, 0x80},
+	}
+	config.GradientAngle = 30
+	config.GradientCenterX = 30
+	config.GradientCenterY = 60
+	config.GradientRadius = 80
+	config.TileSize = 7
+	seed := int64(42)
+	config.Seed = &seed
+	return config
+}
+
+var testModes = []ColorMode{ColorModeSolid, ColorModeTiled, ColorModeNoise, ColorModeGradient, ColorModeRadial, ColorModeConic}
+
+func TestBackgroundsMatchReference(t *testing.T) {
+	sizes := []struct{ width, height int }{{1, 1}, {256, 192}, {333, 517}, {1920, 1080}}
+	for _, mode := range testModes {
+		for _, size := range sizes {
+			t.Run(fmt.Sprintf("%s/%dx%d", mode, size.width, size.height), func(t *testing.T) {
+				config := testConfig(mode, size.width, size.height)
+
+				want := image.NewRGBA(image.Rect(0, 0, size.width, size.height))
+				referenceBackground(NewGenerator(config), want)
+
+				got := image.NewRGBA(image.Rect(0, 0, size.width, size.height))
+				if err := NewGenerator(config).drawBackground(got); err != nil {
+					t.Fatal(err)
+				}
+
+				if !bytes.Equal(got.Pix, want.Pix) {
+					t.Errorf("background differs from the reference renderer")
+				}
+			})
+		}
+	}
+}
+
+func TestVerticalGradientMatchesReference(t *testing.T) {
+	config := testConfig(ColorModeGradient, 640, 480)
+	config.GradientAngle = 0
+
+	want := image.NewRGBA(image.Rect(0, 0, 640, 480))
+	referenceBackground(NewGenerator(config), want)
+
+	got := image.NewRGBA(image.Rect(0, 0, 640, 480))
+	if err := NewGenerator(config).drawBackground(got); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got.Pix, want.Pix) {
+		t.Errorf("vertical gradient differs from the reference renderer")
+	}
+}
+
+// withProcs sets GOMAXPROCS for the duration of the test, so the row bands are drawn
+// concurrently also on single CPU hosts
+func withProcs(t *testing.T, n int) {
+	prev := runtime.GOMAXPROCS(n)
+	t.Cleanup(func() { runtime.GOMAXPROCS(prev) })
+}
+
+func TestParallelBackgroundsMatchReference(t *testing.T) {
+	withProcs(t, 4)
+	for _, mode := range testModes {
+		t.Run(string(mode), func(t *testing.T) {
+			config := testConfig(mode, 1920, 1080)
+
+			want := image.NewRGBA(image.Rect(0, 0, 1920, 1080))
+			referenceBackground(NewGenerator(config), want)
+
+			got := image.NewRGBA(image.Rect(0, 0, 1920, 1080))
+			if err := NewGenerator(config).drawBackground(got); err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(got.Pix, want.Pix) {
+				t.Errorf("background differs from the reference renderer")
+			}
+		})
+	}
+}
+
+func TestNonFiniteGradientGeometry(t *testing.T) {
+	withProcs(t, 4)
+	values := []float64{math.NaN(), math.Inf(1), math.Inf(-1)}
+	sizes := []struct{ width, height int }{{100, 100}, {400, 300}}
+	for _, mode := range []ColorMode{ColorModeGradient, ColorModeRadial, ColorModeConic} {
+		for _, v := range values {
+			for _, size := range sizes {
+				t.Run(fmt.Sprintf("%s/%v/%dx%d", mode, v, size.width, size.height), func(t *testing.T) {
+					config := testConfig(mode, size.width, size.height)
+					config.GradientAngle = v
+					config.GradientCenterX = v
+					config.GradientRadius = v
+
+					img := image.NewRGBA(image.Rect(0, 0, size.width, size.height))
+					if err := NewGenerator(config).drawBackground(img); err != nil {
+						t.Fatal(err)
+					}
+				})
+			}
+		}
+	}
+}
+
+func TestGradientStopsClamp(t *testing.T) {
+	stops := newGradientStops(testConfig(ColorModeGradient, 1, 1).Colors)
+	tests := []struct {
+		t    float64
+		want float64
+	}{
+		{math.NaN(), 0},
+		{-1, 0},
+		{math.Inf(-1), 0},
+		{2, 1},
+		{math.Inf(1), 1},
+	}
+	for _, tt := range tests {
+		if got, want := stops.at(tt.t), stops.at(tt.want); got != want {
+			t.Errorf("at(%v) = %v, want %v", tt.t, got, want)
+		}
+	}
+}
+
+func TestDrawRowsPropagatesPanic(t *testing.T) {
+	withProcs(t, 4)
+	img := image.NewRGBA(image.Rect(0, 0, 512, 512))
+
+	defer func() {
+		if r := recover(); r != "band panic" {
+			t.Errorf("recovered %v, want the panic of the band", r)
+		}
+	}()
+	drawRows(img, func(y0, y1 int) {
+		if y0 > 0 {
+			panic("band panic")
+		}
+	})
+	t.Errorf("drawRows returned without panic")
+}
+
+// BenchmarkBackgrounds compares the former per-pixel renderers ("set") with the
+// row-based renderers writing into RGBA.Pix ("pix"), e.g.:
+//
+//	go test ./pkg/generator -run '^$' -bench 'Backgrounds/gradient'
+func BenchmarkBackgrounds(b *testing.B) {
+	sizes := []struct {
+		name          string
+		width, height int
+	}{
+		{"256x192", 256, 192},
+		{"1920x1080", 1920, 1080},
+		{"8K", 7680, 4320},
+	}
+
+	for _, mode := range testModes {
+		for _, size := range sizes {
+			config := testConfig(mode, size.width, size.height)
+			img := image.NewRGBA(image.Rect(0, 0, size.width, size.height))
+
+			b.Run(fmt.Sprintf("%s/%s/set", mode, size.name), func(b *testing.B) {
+				for i := 0; i < b.N; i++ {
+					referenceBackground(NewGenerator(config), img)
+				}
+			})
+			b.Run(fmt.Sprintf("%s/%s/pix", mode, size.name), func(b *testing.B) {
+				for i := 0; i < b.N; i++ {
+					if err := NewGenerator(config).drawBackground(img); err != nil {
+						b.Fatal(err)
+					}
+				}
+			})
+		}
+	}
+}
+
+func TestRadialAndConicPixels(t *testing.T) {
+	red := color.RGBA{255, 0, 0, 255}
+	lime := color.RGBA{0, 255, 0, 255}
+	blue := color.RGBA{0, 0, 255, 255}
+	redBlue := color.RGBA{128, 0, 128, 255}
+	redLime := color.RGBA{128, 128, 0, 255}
+	limeBlue := color.RGBA{0, 128, 128, 255}
+
+	type pixel struct {
+		x, y int
+		want color.RGBA
+	}
+	tests := []struct {
+		name           string
+		mode           ColorMode
+		colors         []color.Color
+		angle          float64
+		cx, cy, radius float64
+		pixels         []pixel
+	}{
+		{
+			// 200x100 with the center at 50,50: the farthest corner is 158.1 pixels away,
+			// so a radius of 50% is 79.1 pixels
+			name: "radial", mode: ColorModeRadial, colors: []color.Color{red, blue}, cx: 25, cy: 50, radius: 50,
+			pixels: []pixel{{50, 50, red}, {90, 50, redBlue}, {50, 90, redBlue}, {10, 50, redBlue}, {130, 50, blue}, {199, 99, blue}},
+		},
+		{
+			name: "radial default", mode: ColorModeRadial, colors: []color.Color{red, blue}, cx: 50, cy: 50, radius: 100,
+			pixels: []pixel{{100, 50, red}, {0, 0, blue}, {150, 75, redBlue}},
+		},
+		{
+			name: "conic", mode: ColorModeConic, colors: []color.Color{red, lime, blue}, cx: 50, cy: 50,
+			pixels: []pixel{{100, 10, red}, {140, 50, redLime}, {100, 90, lime}, {60, 50, limeBlue}},
+		},
+		{
+			name: "conic start angle", mode: ColorModeConic, colors: []color.Color{red, lime, blue}, angle: 90, cx: 50, cy: 50,
+			pixels: []pixel{{140, 50, red}, {100, 90, redLime}, {60, 50, lime}, {100, 10, limeBlue}},
+		},
+		{
+			name: "conic center", mode: ColorModeConic, colors: []color.Color{red, lime, blue}, cx: 25, cy: 75,
+			pixels: []pixel{{50, 10, red}, {150, 75, redLime}, {10, 75, limeBlue}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := DefaultConfig()
+			config.Width, config.Height = 200, 100
+			config.ColorMode = tt.mode
+			config.Colors = tt.colors
+			config.GradientAngle = tt.angle
+			config.GradientCenterX, config.GradientCenterY = tt.cx, tt.cy
+			config.GradientRadius = tt.radius
+			config.Text = ""
+			img, err := NewGenerator(config).Generate()
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			for _, p := range tt.pixels {
+				got := color.RGBAModel.Convert(img.At(p.x, p.y)).(color.RGBA)
+				diff := max(absDiff(got.R, p.want.R), absDiff(got.G, p.want.G), absDiff(got.B, p.want.B))
+				if diff > 3 || got.A != 255 {
+					t.Errorf("pixel %d,%d = %v, want %v", p.x, p.y, got, p.want)
+				}
+			}
+		})
+	}
+}
+
+// absDiff returns the absolute difference of two color channels
+func absDiff(a, b uint8) int {
+	return max(int(a)-int(b), int(b)-int(a))
+}
@@ -0,0 +1,302 @@
+package generator
+
+import (
+	"bufio"
+	"encoding/xml"
+	"fmt"
+	"image/color"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// svgFontFamily is the font stack used for text in SVG output
+const svgFontFamily = "DejaVu Sans, Helvetica, Arial, sans-serif"
+
+// IsVectorFormat returns true if the given output format is rendered by the SVG backend
+func IsVectorFormat(format string) bool {
+	return strings.ToLower(format) == "svg"
+}
+
+// WriteSVG renders the configuration as SVG vector markup to the given writer
+func (g *Generator) WriteSVG(w io.Writer) error {
+	bw := bufio.NewWriter(w)
+	width, height := g.config.Width, g.config.Height
+
+	fmt.Fprintf(bw, `<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="%d" viewBox="0 0 %d %d">`+"\n",
+		width, height, width, height)
+
+	// Draw background
+	if err := g.writeSVGBackground(bw); err != nil {
+		return err
+	}
+
+	// Draw border
+	if g.config.BorderWidth > 0 {
+		g.writeSVGBorder(bw)
+	}
+
+	// Draw text
+	if g.config.Text != "" {
+		if err := g.writeSVGText(bw); err != nil {
+			return err
+		}
+	}
+
+	fmt.Fprint(bw, "</svg>\n")
+	return bw.Flush()
+}
+
+// writeSVGBackground writes the background based on the color mode
+func (g *Generator) writeSVGBackground(w io.Writer) error {
+	switch g.config.ColorMode {
+	case ColorModeSolid:
+		g.writeSVGSolidBackground(w)
+	case ColorModeTiled:
+		g.writeSVGTiledBackground(w, false)
+	case ColorModeNoise:
+		g.writeSVGTiledBackground(w, true)
+	case ColorModeGradient:
+		g.writeSVGGradientBackground(w)
+	case ColorModeRadial:
+		g.writeSVGRadialBackground(w)
+	case ColorModeConic:
+		g.writeSVGConicBackground(w)
+	default:
+		return fmt.Errorf("unsupported color mode: %s", g.config.ColorMode)
+	}
+	return nil
+}
+
+// writeSVGSolidBackground writes a full-size rect with a solid color
+func (g *Generator) writeSVGSolidBackground(w io.Writer) {
+	fmt.Fprintf(w, `<rect width="%d" height="%d" %s/>`+"\n",
+		g.config.Width, g.config.Height, svgPaint("fill", g.config.Colors[0]))
+}
+
+// writeSVGTiledBackground writes the tiles as rect grid, with one path per color
+func (g *Generator) writeSVGTiledBackground(w io.Writer, random bool) {
+	tileSize := g.config.TileSize
+	if tileSize <= 0 {
+		tileSize = 16
+	}
+
+	colors := g.config.Colors
+	if len(colors) == 0 {
+		colors = []color.Color{color.Black, color.White}
+	}
+
+	// Collect the tile rects per color index, using the same color selection as the raster backend
+	paths := make([]strings.Builder, len(colors))
+	colorIndex := 0
+	for y := 0; y < g.config.Height; y += tileSize {
+		for x := 0; x < g.config.Width; x += tileSize {
+			var idx int
+			if random {
+				idx = g.rng.Intn(len(colors))
+			} else {
+				idx = colorIndex % len(colors)
+				colorIndex++
+			}
+
+			tw := min(x+tileSize, g.config.Width) - x
+			th := min(y+tileSize, g.config.Height) - y
+			fmt.Fprintf(&paths[idx], "M%d %dh%dv%dh%dz", x, y, tw, th, -tw)
+		}
+	}
+
+	for i, path := range paths {
+		if path.Len() == 0 {
+			continue
+		}
+		fmt.Fprintf(w, `<path d="%s" %s/>`+"\n", path.String(), svgPaint("fill", colors[i]))
+	}
+}
+
+// writeSVGGradientBackground writes a linearGradient along the gradient angle
+func (g *Generator) writeSVGGradientBackground(w io.Writer) {
+	colors := g.config.Colors
+	if len(colors) < 2 {
+		// Fall back to solid color
+		g.writeSVGSolidBackground(w)
+		return
+	}
+
+	width := float64(g.config.Width)
+	height := float64(g.config.Height)
+
+	// Same direction vector as the raster backend:
+	// 0 degrees = top to bottom, 90 degrees = left to right
+	angleRad := g.config.GradientAngle * math.Pi / 180.0
+	dx := math.Sin(angleRad)
+	dy := math.Cos(angleRad)
+
+	// Project the corners onto the gradient direction to find the gradient start and end
+	minProj, maxProj := math.Inf(1), math.Inf(-1)
+	for _, corner := range [][2]float64{{0, 0}, {width, 0}, {0, height}, {width, height}} {
+		proj := corner[0]*dx + corner[1]*dy
+		minProj = math.Min(minProj, proj)
+		maxProj = math.Max(maxProj, proj)
+	}
+
+	// Start and end points on the line through the image center
+	cx, cy := width/2, height/2
+	centerProj := cx*dx + cy*dy
+	x1, y1 := cx+dx*(minProj-centerProj), cy+dy*(minProj-centerProj)
+	x2, y2 := cx+dx*(maxProj-centerProj), cy+dy*(maxProj-centerProj)
+
+	fmt.Fprintf(w, `<defs><linearGradient id="bg" gradientUnits="userSpaceOnUse" x1="%s" y1="%s" x2="%s" y2="%s">`,
+		svgNum(x1), svgNum(y1), svgNum(x2), svgNum(y2))
+	writeSVGStops(w, colors)
+	fmt.Fprint(w, "</linearGradient></defs>\n")
+
+	fmt.Fprintf(w, `<rect width="%d" height="%d" fill="url(#bg)"/>`+"\n", g.config.Width, g.config.Height)
+}
+
+// writeSVGRadialBackground writes a radialGradient around the gradient center
+func (g *Generator) writeSVGRadialBackground(w io.Writer) {
+	colors := g.config.Colors
+	if len(colors) < 2 {
+		// Fall back to solid color
+		g.writeSVGSolidBackground(w)
+		return
+	}
+
+	cx, cy, radius := g.radialGeometry()
+
+	fmt.Fprintf(w, `<defs><radialGradient id="bg" gradientUnits="userSpaceOnUse" cx="%s" cy="%s" r="%s">`,
+		svgNum(cx), svgNum(cy), svgNum(radius))
+	writeSVGStops(w, colors)
+	fmt.Fprint(w, "</radialGradient></defs>\n")
+
+	fmt.Fprintf(w, `<rect width="%d" height="%d" fill="url(#bg)"/>`+"\n", g.config.Width, g.config.Height)
+}
+
+// writeSVGConicBackground approximates a conic gradient with one wedge per degree,
+// as SVG has no native conic gradient
+func (g *Generator) writeSVGConicBackground(w io.Writer) {
+	colors := g.config.Colors
+	if len(colors) < 2 {
+		// Fall back to solid color
+		g.writeSVGSolidBackground(w)
+		return
+	}
+
+	const wedges = 360
+	cx, cy, _ := g.radialGeometry()
+
+	// Wedges must reach beyond the farthest corner
+	reach := 2 * math.Hypot(float64(g.config.Width), float64(g.config.Height))
+
+	fmt.Fprintf(w, `<rect width="%d" height="%d" %s/>`+"\n", g.config.Width, g.config.Height, svgPaint("fill", colors[0]))
+	for i := 0; i < wedges; i++ {
+		// Angles in degrees, 0 pointing up, clockwise; slightly overlapping to avoid seams
+		from := g.config.GradientAngle + float64(i)*360/wedges
+		to := from + 360/wedges
+		if i < wedges-1 {
+			to += 0.5
+		}
+		t := (float64(i) + 0.5) / wedges
+
+		fromRad := from * math.Pi / 180
+		toRad := to * math.Pi / 180
+		fmt.Fprintf(w, `<path d="M%s %sL%s %sL%s %sz" %s/>`+"\n",
+			svgNum(cx), svgNum(cy),
+			svgNum(cx+reach*math.Sin(fromRad)), svgNum(cy-reach*math.Cos(fromRad)),
+			svgNum(cx+reach*math.Sin(toRad)), svgNum(cy-reach*math.Cos(toRad)),
+			svgPaint("fill", gradientColor(colors, t)))
+	}
+}
+
+// writeSVGStops writes evenly distributed gradient stops for the given colors
+func writeSVGStops(w io.Writer, colors []color.Color) {
+	for i, c := range colors {
+		offset := float64(i) / float64(len(colors)-1)
+		fmt.Fprintf(w, `<stop offset="%s" %s/>`, svgNum(offset), svgPaint("stop-color", c))
+	}
+}
+
+// writeSVGBorder writes the border as a frame path (outer rect minus inner rect)
+func (g *Generator) writeSVGBorder(w io.Writer) {
+	width, height := g.config.Width, g.config.Height
+	bw := g.config.BorderWidth
+
+	d := fmt.Sprintf("M0 0H%dV%dH0z", width, height)
+	if bw*2 < width && bw*2 < height {
+		d += fmt.Sprintf("M%d %dV%dH%dV%dz", bw, bw, height-bw, width-bw, bw)
+	}
+
+	fmt.Fprintf(w, `<path fill-rule="evenodd" d="%s" %s/>`+"\n", d, svgPaint("fill", g.config.BorderColor))
+}
+
+// writeSVGText writes the centered text with an inverted outline and optional rotation.
+// Multiple lines are written as tspan elements, laid out with the metrics of the raster font.
+func (g *Generator) writeSVGText(w io.Writer) error {
+	textColor := g.textColor()
+	cx := float64(g.config.Width) / 2
+	cy := float64(g.config.Height) / 2
+
+	transform := ""
+	if g.config.TextAngle != 0 {
+		transform = fmt.Sprintf(` transform="rotate(%s %s %s)"`, svgNum(g.config.TextAngle), svgNum(cx), svgNum(cy))
+	}
+
+	content := g.textContent()
+	var text, family strings.Builder
+	xml.EscapeText(&family, []byte(g.svgFontFamily()))
+	if g.config.TextWrap <= 0 && len(textLines(content)) == 1 {
+		xml.EscapeText(&text, []byte(content))
+	} else {
+		face, release, err := g.loadFont()
+		if err != nil {
+			return err
+		}
+		layout := g.layoutText(content, face)
+		release()
+
+		// Center the block of lines vertically around the image center
+		for i, line := range layout.lines {
+			y := cy + (float64(i)-float64(len(layout.lines)-1)/2)*float64(layout.lineHeight)
+			fmt.Fprintf(&text, `<tspan x="%s" y="%s">`, svgNum(cx), svgNum(y))
+			xml.EscapeText(&text, []byte(line.text))
+			text.WriteString("</tspan>")
+		}
+	}
+
+	fmt.Fprintf(w, `<text x="%s" y="%s" font-family="%s" font-size="%s" text-anchor="middle" dominant-baseline="central" %s %s stroke-width="2" paint-order="stroke"%s>%s</text>`+"\n",
+		svgNum(cx), svgNum(cy), family.String(), svgNum(g.config.TextSize),
+		svgPaint("fill", textColor), svgPaint("stroke", invertColor(textColor)),
+		transform, text.String())
+	return nil
+}
+
+// svgFontFamily returns the font stack of the text, starting with the family of the
+// configured font, or of the default font. Wrapped lines are measured with this font, so
+// it is preferred over the generic fallbacks wherever it is installed.
+func (g *Generator) svgFontFamily() string {
+	info, err := LookupFont(g.config.FontName)
+	if err != nil || info.Path == "" || info.Family == "" {
+		return svgFontFamily
+	}
+	return "'" + info.Family + "', " + svgFontFamily
+}
+
+// svgPaint returns a paint attribute (and opacity, if needed) for the given color
+func svgPaint(attr string, c color.Color) string {
+	rgba := color.NRGBAModel.Convert(c).(color.NRGBA)
+	paint := fmt.Sprintf(`%s="#%02x%02x%02x"`, attr, rgba.R, rgba.G, rgba.B)
+	if rgba.A < 255 {
+		opacityAttr := attr + "-opacity"
+		if attr == "stop-color" {
+			opacityAttr = "stop-opacity"
+		}
+		paint += fmt.Sprintf(` %s="%s"`, opacityAttr, svgNum(float64(rgba.A)/255))
+	}
+	return paint
+}
+
+// svgNum formats a number with at most 3 decimals
+func svgNum(v float64) string {
+	return strconv.FormatFloat(math.Round(v*1000)/1000, 'f', -1, 64)
+}
@@ -0,0 +1,160 @@
+package generator
+
+import (
+	"bytes"
+	"encoding/xml"
+	"image/color"
+	"io"
+	"strings"
+	"testing"
+)
+
+// renderSVG renders the default configuration with the given changes as SVG, and checks
+// that the output is well-formed XML
+func renderSVG(t *testing.T, modify func(c *ImageConfig)) string {
+	t.Helper()
+	seed := int64(1)
+	config := DefaultConfig()
+	config.Format = "svg"
+	config.Seed = &seed
+	modify(config)
+
+	var buf bytes.Buffer
+	if err := NewGenerator(config).WriteSVG(&buf); err != nil {
+		t.Fatal(err)
+	}
+
+	dec := xml.NewDecoder(bytes.NewReader(buf.Bytes()))
+	for {
+		if _, err := dec.Token(); err == io.EOF {
+			break
+		} else if err != nil {
+			t.Fatalf("invalid SVG: %v\n%s", err, buf.String())
+		}
+	}
+	return buf.String()
+}
+
+func TestWriteSVG(t *testing.T) {
+	red := color.RGBA{255, 0, 0, 255}
+	blue := color.RGBA{0, 0, 255, 255}
+
+	tests := []struct {
+		name   string
+		modify func(c *ImageConfig)
+		want   []string // substrings of the output
+		count  map[string]int
+	}{
+		{
+			name:   "solid",
+			modify: func(c *ImageConfig) { c.Width, c.Height = 400, 300 },
+			want:   []string{`width="400" height="300" viewBox="0 0 400 300"`, `<rect width="400" height="300" fill="#808080"/>`, ">400x300</text>"},
+		},
+		{
+			name: "transparent color",
+			modify: func(c *ImageConfig) {
+				c.Colors = []color.Color{color.NRGBA{255, 0, 0, 128}}
+			},
+			want: []string{`fill="#ff0000" fill-opacity="0.502"`},
+		},
+		{
+			name: "tiles",
+			modify: func(c *ImageConfig) {
+				c.ColorMode, c.Colors, c.TileSize = ColorModeTiled, []color.Color{red, blue}, 64
+			},
+			want:  []string{`fill="#ff0000"`, `fill="#0000ff"`, "M0 0h64v64h-64z"},
+			count: map[string]int{"<path ": 2},
+		},
+		{
+			name: "gradient",
+			modify: func(c *ImageConfig) {
+				c.ColorMode, c.Colors, c.GradientAngle = ColorModeGradient, []color.Color{red, color.White, blue}, 90
+			},
+			want:  []string{`<linearGradient id="bg" gradientUnits="userSpaceOnUse" x1="0" y1="96" x2="256" y2="96">`, `<stop offset="0.5" stop-color="#ffffff"/>`, `fill="url(#bg)"`},
+			count: map[string]int{"<stop ": 3},
+		},
+		{
+			name: "radial",
+			modify: func(c *ImageConfig) {
+				c.ColorMode, c.Colors = ColorModeRadial, []color.Color{red, blue}
+				c.GradientCenterX, c.GradientCenterY, c.GradientRadius = 50, 50, 100
+			},
+			want: []string{`<radialGradient id="bg" gradientUnits="userSpaceOnUse" cx="128" cy="96" r="160">`},
+		},
+		{
+			name: "conic",
+			modify: func(c *ImageConfig) {
+				c.ColorMode, c.Colors = ColorModeConic, []color.Color{red, blue}
+				c.GradientCenterX, c.GradientCenterY = 50, 50
+			},
+			count: map[string]int{"<path ": 360},
+		},
+		{
+			name: "gradient with one color",
+			modify: func(c *ImageConfig) {
+				c.ColorMode, c.Colors = ColorModeGradient, []color.Color{red}
+			},
+			want:  []string{`<rect width="256" height="192" fill="#ff0000"/>`},
+			count: map[string]int{"Gradient": 0},
+		},
+		{
+			name: "border",
+			modify: func(c *ImageConfig) {
+				c.BorderWidth, c.BorderColor = 5, blue
+			},
+			want: []string{`<path fill-rule="evenodd" d="M0 0H256V192H0zM5 5V187H251V5z" fill="#0000ff"/>`},
+		},
+		{
+			name:   "no text",
+			modify: func(c *ImageConfig) { c.Text = "" },
+			count:  map[string]int{"<text": 0},
+		},
+		{
+			name: "escaped and rotated text",
+			modify: func(c *ImageConfig) {
+				c.Text, c.TextSize, c.TextAngle = `<a & "b">`, 32, -45
+			},
+			want: []string{`font-size="32"`, `transform="rotate(-45 128 96)"`, ">&lt;a &amp; &#34;b&#34;&gt;</text>"},
+		},
+		{
+			name: "text color and outline",
+			modify: func(c *ImageConfig) {
+				var textColor color.Color = color.White
+				c.TextColor = &textColor
+			},
+			want: []string{`fill="#ffffff" stroke="#000000" stroke-width="2"`},
+		},
+		{
+			name: "line breaks",
+			modify: func(c *ImageConfig) {
+				c.Text = `one\ntwo` + "\nthree"
+			},
+			want:  []string{`<tspan x="128" y="`, ">one</tspan>", ">two</tspan>", ">three</tspan>"},
+			count: map[string]int{"<tspan ": 3},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			svg := renderSVG(t, tt.modify)
+			for _, want := range tt.want {
+				if !strings.Contains(svg, want) {
+					t.Errorf("SVG does not contain %s:\n%s", want, svg)
+				}
+			}
+			for substr, want := range tt.count {
+				if got := strings.Count(svg, substr); got != want {
+					t.Errorf("SVG contains %q %d times, want %d", substr, got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestWriteSVGNoiseIsReproducible(t *testing.T) {
+	noise := func(c *ImageConfig) {
+		c.ColorMode, c.Colors, c.TileSize = ColorModeNoise, []color.Color{color.Black, color.White}, 8
+	}
+	if a, b := renderSVG(t, noise), renderSVG(t, noise); a != b {
+		t.Error("noise with the same seed differs between two renders")
+	}
+}
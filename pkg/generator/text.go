@@ -0,0 +1,121 @@
+package generator
+
+import (
+	"image"
+	"image/color"
+	"strings"
+
+	"golang.org/x/image/font"
+	"golang.org/x/image/math/fixed"
+)
+
+// textLine is a line of text with its measured width in pixels
+type textLine struct {
+	text  string
+	width int
+}
+
+// textLayout holds the lines of a text block, each centered horizontally
+type textLayout struct {
+	lines      []textLine
+	width      int // width of the widest line
+	height     int // height of the block, from the top of the first to the bottom of the last line
+	inkHeight  int // height of the tallest line
+	lineHeight int // distance between the baselines of two lines
+}
+
+// textLines splits the text into lines at line breaks, and the escape sequence "\n"
+func textLines(text string) []string {
+	text = strings.ReplaceAll(text, `\n`, "\n")
+	text = strings.ReplaceAll(text, "\r\n", "\n")
+	return strings.Split(text, "\n")
+}
+
+// layoutText splits the text into lines and wraps them at word boundaries to the configured
+// maximum width. Words wider than the maximum width get a line of their own.
+func (g *Generator) layoutText(text string, face font.Face) textLayout {
+	maxWidth := fixed.I(int(float64(g.config.Width) * g.config.TextWrap / 100))
+	lineHeight := g.config.LineHeight
+	if lineHeight <= 0 {
+		lineHeight = 1
+	}
+
+	var layout textLayout
+	addLine := func(line string) {
+		bounds, _ := font.BoundString(face, line)
+		width := (bounds.Max.X - bounds.Min.X).Ceil()
+		layout.lines = append(layout.lines, textLine{text: line, width: width})
+		layout.width = max(layout.width, width)
+		layout.inkHeight = max(layout.inkHeight, (bounds.Max.Y - bounds.Min.Y).Ceil())
+	}
+
+	for _, paragraph := range textLines(text) {
+		if g.config.TextWrap <= 0 || font.MeasureString(face, paragraph) <= maxWidth {
+			addLine(paragraph)
+			continue
+		}
+
+		line := ""
+		for _, word := range strings.Fields(paragraph) {
+			candidate := word
+			if line != "" {
+				candidate = line + " " + word
+			}
+			if line != "" && font.MeasureString(face, candidate) > maxWidth {
+				addLine(line)
+				candidate = word
+			}
+			line = candidate
+		}
+		addLine(line)
+	}
+
+	layout.lineHeight = int(float64(face.Metrics().Height.Ceil())*lineHeight + 0.5)
+	layout.height = layout.inkHeight + (len(layout.lines)-1)*layout.lineHeight
+	return layout
+}
+
+// drawTextBlock draws the lines centered in an area of the given size at the top left of
+// dst, with a one pixel outline in the border color
+func drawTextBlock(dst *image.RGBA, layout textLayout, width, height int, textColor, borderColor color.Color, face font.Face) {
+	// Baseline of the first line, so the block is centered vertically
+	top := (height+layout.inkHeight)/2 - (len(layout.lines)-1)*layout.lineHeight/2
+
+	// Draw text border (outline) by drawing the text in 8 directions with border color
+	borderOffsets := []struct{ dx, dy int }{
+		{-1, -1}, {0, -1}, {1, -1},
+		{-1, 0}, {1, 0},
+		{-1, 1}, {0, 1}, {1, 1},
+	}
+
+	borderDrawer := &font.Drawer{
+		Dst:  dst,
+		Src:  image.NewUniform(borderColor),
+		Face: face,
+	}
+	drawer := &font.Drawer{
+		Dst:  dst,
+		Src:  image.NewUniform(textColor),
+		Face: face,
+	}
+
+	// Draw the outlines of all lines first, so they never cover the text of another line
+	for i, line := range layout.lines {
+		basePoint := layout.linePoint(i, width, top)
+		for _, offset := range borderOffsets {
+			borderDrawer.Dot = basePoint.Add(fixed.P(offset.dx, offset.dy))
+			borderDrawer.DrawString(line.text)
+		}
+	}
+
+	// Draw the main text on top
+	for i, line := range layout.lines {
+		drawer.Dot = layout.linePoint(i, width, top)
+		drawer.DrawString(line.text)
+	}
+}
+
+// linePoint returns the start of the baseline of line i, centered in the given width
+func (l textLayout) linePoint(i, width, top int) fixed.Point26_6 {
+	return fixed.P((width-l.lines[i].width)/2, top+i*l.lineHeight)
+}
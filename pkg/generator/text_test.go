@@ -0,0 +1,184 @@
+package generator
+
+import (
+	"slices"
+	"strings"
+	"testing"
+
+	"golang.org/x/image/font"
+)
+
+func TestTextLines(t *testing.T) {
+	tests := []struct {
+		text string
+		want []string
+	}{
+		{"one line", []string{"one line"}},
+		{`one\ntwo`, []string{"one", "two"}},
+		{"one\ntwo", []string{"one", "two"}},
+		{"one\r\ntwo", []string{"one", "two"}},
+		{`one\n\nthree`, []string{"one", "", "three"}},
+		{`trailing\n`, []string{"trailing", ""}},
+		{"", []string{""}},
+	}
+	for _, tt := range tests {
+		if got := textLines(tt.text); !slices.Equal(got, tt.want) {
+			t.Errorf("textLines(%q) = %q, want %q", tt.text, got, tt.want)
+		}
+	}
+}
+
+// layoutWith lays out the text with the embedded default font in the given configuration
+func layoutWith(t *testing.T, text string, modify func(c *ImageConfig)) (textLayout, font.Face, int) {
+	t.Helper()
+	config := DefaultConfig()
+	config.Width = 400
+	config.TextSize = 20
+	modify(config)
+
+	g := NewGenerator(config)
+	face, release, err := g.loadFont()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(release)
+	maxWidth := int(float64(config.Width) * config.TextWrap / 100)
+	return g.layoutText(text, face), face, maxWidth
+}
+
+func TestLayoutTextWrapping(t *testing.T) {
+	long := "The quick brown fox jumps over the lazy dog and keeps running through the field"
+	tests := []struct {
+		name   string
+		text   string
+		wrap   float64
+		want   []string // nil means only the width limit is checked
+		checkW bool     // every line fits into the wrap width
+	}{
+		{"no wrapping", long, 0, []string{long}, false},
+		{"short text", "short", 50, []string{"short"}, true},
+		{"wrapped", long, 50, nil, true},
+		{"narrow", "aa bb cc", 1, []string{"aa", "bb", "cc"}, false},
+		{"long word on its own line", "a " + strings.Repeat("w", 80) + " b", 20, []string{"a", strings.Repeat("w", 80), "b"}, false},
+		{"line breaks", `first\nsecond`, 0, []string{"first", "second"}, false},
+		{"line breaks and wrapping", `aa bb\ncc dd`, 1, []string{"aa", "bb", "cc", "dd"}, false},
+		{"empty line", `aa\n\nbb`, 50, []string{"aa", "", "bb"}, true},
+		{"spaces collapse when wrapping", "aa   bb", 1, []string{"aa", "bb"}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			layout, face, maxWidth := layoutWith(t, tt.text, func(c *ImageConfig) { c.TextWrap = tt.wrap })
+
+			var lines []string
+			for _, line := range layout.lines {
+				lines = append(lines, line.text)
+				if tt.checkW && font.MeasureString(face, line.text).Ceil() > maxWidth {
+					t.Errorf("line %q is wider than %d pixels", line.text, maxWidth)
+				}
+			}
+			if tt.want != nil && !slices.Equal(lines, tt.want) {
+				t.Errorf("lines = %q, want %q", lines, tt.want)
+			}
+			if tt.want == nil {
+				if len(lines) < 2 {
+					t.Errorf("lines = %q, want the text wrapped", lines)
+				}
+				if strings.Join(lines, " ") != tt.text {
+					t.Errorf("lines = %q, want all words of %q in order", lines, tt.text)
+				}
+			}
+		})
+	}
+}
+
+func TestLayoutTextMetrics(t *testing.T) {
+	single, face, _ := layoutWith(t, "Hello", func(c *ImageConfig) {})
+	lineHeight := face.Metrics().Height.Ceil()
+	if single.lineHeight != lineHeight {
+		t.Errorf("line height = %d, want the font's line height %d", single.lineHeight, lineHeight)
+	}
+	if single.height != single.inkHeight {
+		t.Errorf("height of one line = %d, want its ink height %d", single.height, single.inkHeight)
+	}
+	if single.width != single.lines[0].width || single.width <= 0 {
+		t.Errorf("width = %d, want the width of the line %d", single.width, single.lines[0].width)
+	}
+
+	tests := []struct {
+		name           string
+		lineHeight     float64
+		wantLineHeight int
+	}{
+		{"default", 1, lineHeight},
+		{"double", 2, 2 * lineHeight},
+		{"tight", 0.5, int(float64(lineHeight)*0.5 + 0.5)},
+		{"invalid falls back to 1", 0, lineHeight},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			layout, _, _ := layoutWith(t, `Hello\nWorld\nAgain`, func(c *ImageConfig) { c.LineHeight = tt.lineHeight })
+			if layout.lineHeight != tt.wantLineHeight {
+				t.Errorf("line height = %d, want %d", layout.lineHeight, tt.wantLineHeight)
+			}
+			if want := layout.inkHeight + 2*tt.wantLineHeight; layout.height != want {
+				t.Errorf("height = %d, want %d", layout.height, want)
+			}
+			widest := 0
+			for _, line := range layout.lines {
+				widest = max(widest, line.width)
+			}
+			if layout.width != widest {
+				t.Errorf("width = %d, want the widest line %d", layout.width, widest)
+			}
+		})
+	}
+}
+
+// textRows renders the text and returns the first and last row with text pixels
+func textRows(t *testing.T, text string) (top, bottom int) {
+	t.Helper()
+	config := DefaultConfig()
+	config.Width, config.Height = 300, 200
+	config.Text = text
+	config.TextSize = 30
+	img, err := NewGenerator(config).Generate()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	background := img.At(0, 0)
+	top, bottom = -1, -1
+	for y := 0; y < config.Height; y++ {
+		for x := 0; x < config.Width; x++ {
+			if img.At(x, y) != background {
+				if top == -1 {
+					top = y
+				}
+				bottom = y
+				break
+			}
+		}
+	}
+	if top == -1 {
+		t.Fatalf("no text drawn for %q", text)
+	}
+	return top, bottom
+}
+
+func TestMultiLineTextIsCentered(t *testing.T) {
+	layout, _, _ := layoutWith(t, "Top", func(c *ImageConfig) { c.TextSize = 30 })
+	top1, bottom1 := textRows(t, "Top")
+
+	for _, n := range []int{2, 3} {
+		text := strings.Repeat(`Top\n`, n-1) + "Top"
+		top, bottom := textRows(t, text)
+
+		// Every further line adds one line height, and the block stays centered
+		if got, want := bottom-top, bottom1-top1+(n-1)*layout.lineHeight; got < want-1 || got > want+1 {
+			t.Errorf("%d lines cover %d rows, want %d", n, got, want)
+		}
+		if diff := (top + bottom) - (top1 + bottom1); diff < -2 || diff > 2 {
+			t.Errorf("%d lines cover rows %d to %d, want them centered like the single line at %d to %d", n, top, bottom, top1, bottom1)
+		}
+	}
+}
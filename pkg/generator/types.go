@@ -12,6 +12,8 @@ const (
 	ColorModeTiled    ColorMode = "tiled"
 	ColorModeGradient ColorMode = "gradient"
 	ColorModeNoise    ColorMode = "noise"
+	ColorModeRadial   ColorMode = "radial"
+	ColorModeConic    ColorMode = "conic"
 )
 
 // ImageConfig holds the configuration for generating an image
@@ -20,16 +22,32 @@ type ImageConfig struct {
 	Height        int
 	ColorMode     ColorMode
 	Colors        []color.Color
-	GradientAngle float64
+	GradientAngle float64 // linear gradient direction, or conic start angle
 	TileSize      int
 	Text          string
 	TextSize      float64
 	TextColor     *color.Color
 	TextAngle     float64
+	TextWrap      float64 // maximum line width in percent of the image width, 0 disables wrapping
+	LineHeight    float64 // distance between lines as a multiple of the font's line height
 	FontName      string
 	BorderWidth   int
 	BorderColor   color.Color
-	Format        string // png, jpeg, webp
+	Format        string // png, jpeg, webp, gif, apng, svg
+	Quality       int    // encoder quality (1-100) for jpeg and lossy webp
+	Lossless      bool   // use lossless compression (webp)
+	Seed          *int64 // seed for all random decisions, nil means non-deterministic
+
+	// Radial / conic gradient settings
+	GradientCenterX float64 // center x in percent of the width
+	GradientCenterY float64 // center y in percent of the height
+	GradientRadius  float64 // radial gradient radius in percent of the distance to the farthest corner
+
+	// Animation settings (gif, apng)
+	Frames          int     // number of frames, 1 or less creates a still image
+	FrameDelay      int     // delay between frames in milliseconds
+	FrameAngleStep  float64 // gradient angle change per frame in degrees
+	FrameColorShift bool    // rotate the colors by one position per frame
 }
 
 // DefaultConfig returns a default image configuration
@@ -45,9 +63,19 @@ func DefaultConfig() *ImageConfig {
 		TextSize:      20,
 		TextColor:     nil, // nil means auto (white or XOR)
 		TextAngle:     0,
+		TextWrap:      0,
+		LineHeight:    1,
 		FontName:      "",
 		BorderWidth:   0,
 		BorderColor:   color.Black,
 		Format:        "png",
+		Quality:       90,
+		Lossless:      false,
+		Frames:        1,
+		FrameDelay:    100,
+
+		GradientCenterX: 50,
+		GradientCenterY: 50,
+		GradientRadius:  100,
 	}
 }
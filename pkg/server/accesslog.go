@@ -0,0 +1,61 @@
+package server
+
+import (
+	"fmt"
+	"log/slog"
+	"net/http"
+	"time"
+)
+
+// requestInfo holds details of a request, collected while serving it for the
+// metrics and the access log
+type requestInfo struct {
+	width    int
+	height   int
+	format   string
+	mode     string
+	cache    string        // "HIT" or "MISS", empty if the image is not cacheable
+	render   time.Duration // time spent rendering and encoding, 0 if not rendered
+	internal bool          // admin, metrics and not found requests, logged at debug level
+}
+
+// logRequest writes the access log entry of a finished request. Internal requests
+// (health checks, metrics scrapes) are logged at debug level, server errors as warnings.
+func (h *imageHandler) logRequest(r *http.Request, rec *statusRecorder, info *requestInfo, duration time.Duration) {
+	level := slog.LevelInfo
+	switch {
+	case info.internal:
+		level = slog.LevelDebug
+	case rec.status >= http.StatusInternalServerError:
+		level = slog.LevelWarn
+	}
+	if !h.logger.Enabled(r.Context(), level) {
+		return
+	}
+
+	attrs := []slog.Attr{
+		slog.String("method", r.Method),
+		slog.String("path", r.URL.RequestURI()),
+	}
+	if info.width > 0 {
+		attrs = append(attrs,
+			slog.String("size", fmt.Sprintf("%dx%d", info.width, info.height)),
+			slog.String("mode", info.mode),
+			slog.String("format", info.format),
+		)
+	}
+	attrs = append(attrs,
+		slog.Int("status", rec.status),
+		slog.Int64("bytes", rec.bytes),
+		slog.Duration("duration", duration),
+	)
+	if info.render > 0 {
+		attrs = append(attrs, slog.Duration("render", info.render))
+	}
+	if info.cache != "" {
+		attrs = append(attrs, slog.String("cache", info.cache))
+	}
+	attrs = append(attrs, slog.String("remote", r.RemoteAddr))
+
+	h.logger.LogAttrs(r.Context(), level, "request", attrs...)
+}
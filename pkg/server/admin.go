@@ -0,0 +1,98 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"runtime"
+	"runtime/debug"
+	"strings"
+
+	"github.com/bylexus/imagen/pkg/generator"
+	"github.com/bylexus/imagen/pkg/spec"
+)
+
+// adminPrefix is the path prefix of the admin endpoints, relative to the base path.
+// Image sizes never start with an underscore, so the prefix cannot collide with image URLs.
+const adminPrefix = "/_imagen/"
+
+// Version is the build version of imagen, reported by /_imagen/version. It can be set at
+// build time with -ldflags "-X github.com/bylexus/imagen/pkg/server.Version=1.2.3".
+// If empty, the module version from the build info is used.
+var Version = ""
+
+// VersionInfo describes the running imagen build
+type VersionInfo struct {
+	Version   string   `json:"version"`
+	Revision  string   `json:"revision,omitempty"`
+	GoVersion string   `json:"goVersion"`
+	Font      string   `json:"font"`
+	Formats   []string `json:"formats"`
+}
+
+// buildVersionInfo collects the version of the running binary
+func buildVersionInfo() VersionInfo {
+	info := VersionInfo{
+		Version:   Version,
+		GoVersion: runtime.Version(),
+		Font:      generator.FontName(),
+		Formats:   spec.SupportedFormats(),
+	}
+	if build, ok := debug.ReadBuildInfo(); ok {
+		if info.Version == "" {
+			info.Version = build.Main.Version
+		}
+		for _, setting := range build.Settings {
+			if setting.Key == "vcs.revision" {
+				info.Revision = setting.Value
+			}
+		}
+	}
+	if info.Version == "" {
+		info.Version = "unknown"
+	}
+	return info
+}
+
+// serveAdmin serves the admin endpoints below /_imagen/
+func (h *imageHandler) serveAdmin(w http.ResponseWriter, r *http.Request, path string) {
+	switch strings.TrimPrefix(path, adminPrefix) {
+	case "health":
+		// The process is alive and serving requests
+		h.writeJSON(w, http.StatusOK, map[string]string{"status": "ok"})
+	case "ready":
+		// Not ready anymore while shutting down, so no new requests are routed here
+		if h.shuttingDown.Load() {
+			h.writeJSON(w, http.StatusServiceUnavailable, map[string]string{"status": "shutting down"})
+			return
+		}
+		h.writeJSON(w, http.StatusOK, map[string]string{"status": "ready"})
+	case "version":
+		h.writeJSON(w, http.StatusOK, h.versionInfo())
+	case "stats":
+		h.serveStats(w)
+	case "fonts":
+		// The fonts that can be selected with f: or the font query parameter
+		h.writeJSON(w, http.StatusOK, generator.AvailableFonts())
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+// serveStats returns the image cache statistics as JSON
+func (h *imageHandler) serveStats(w http.ResponseWriter) {
+	stats := map[string]any{}
+	if cache, ok := h.cache.(interface{ Stats() CacheStats }); ok {
+		stats["cache"] = cache.Stats()
+	}
+	h.writeJSON(w, http.StatusOK, stats)
+}
+
+// writeJSON writes the value as an uncached JSON response
+func (h *imageHandler) writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", cacheControlNoStore)
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		h.logger.Error("Failed to write response", "error", err)
+	}
+}
@@ -0,0 +1,106 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"testing"
+
+	"github.com/bylexus/imagen/pkg/generator"
+	"github.com/bylexus/imagen/pkg/spec"
+)
+
+func TestAdminEndpoints(t *testing.T) {
+	handler := Handler(testOptions())
+	size := get(handler, "/100x50", nil).Body.Len()
+	get(handler, "/100x50", nil)
+
+	var formats []any
+	for _, format := range spec.SupportedFormats() {
+		formats = append(formats, format)
+	}
+
+	tests := []struct {
+		path       string
+		wantStatus int
+		want       map[string]any // expected top-level fields, compared as decoded JSON
+	}{
+		{"/_imagen/health", http.StatusOK, map[string]any{"status": "ok"}},
+		{"/_imagen/ready", http.StatusOK, map[string]any{"status": "ready"}},
+		{"/_imagen/version", http.StatusOK, map[string]any{
+			"font":      generator.FontName(),
+			"formats":   formats,
+			"goVersion": buildVersionInfo().GoVersion,
+		}},
+		{"/_imagen/stats", http.StatusOK, map[string]any{"cache": map[string]any{
+			"hits": 1.0, "misses": 1.0, "evictions": 0.0, "entries": 1.0,
+			"bytes": float64(size), "maxEntries": 1000.0, "maxBytes": float64(64 << 20),
+		}}},
+		{"/_imagen/unknown", http.StatusNotFound, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			rec := get(handler, tt.path, nil)
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d", rec.Code, tt.wantStatus)
+			}
+			if tt.want == nil {
+				return
+			}
+			if got := rec.Header().Get("Content-Type"); got != "application/json" {
+				t.Errorf("Content-Type = %q, want application/json", got)
+			}
+			if got := rec.Header().Get("Cache-Control"); got != cacheControlNoStore {
+				t.Errorf("Cache-Control = %q, want %q", got, cacheControlNoStore)
+			}
+
+			var body map[string]any
+			if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+				t.Fatalf("invalid JSON %q: %v", rec.Body.String(), err)
+			}
+			for key, want := range tt.want {
+				if !reflect.DeepEqual(body[key], want) {
+					t.Errorf("%s = %v, want %v", key, body[key], want)
+				}
+			}
+		})
+	}
+}
+
+func TestAdminFonts(t *testing.T) {
+	rec := get(Handler(testOptions()), "/_imagen/fonts", nil)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, want %d", rec.Code, http.StatusOK)
+	}
+
+	var fonts []generator.FontInfo
+	if err := json.Unmarshal(rec.Body.Bytes(), &fonts); err != nil {
+		t.Fatalf("invalid JSON %q: %v", rec.Body.String(), err)
+	}
+	embedded := 0
+	for _, font := range fonts {
+		if font.Embedded {
+			embedded++
+		}
+		if font.Family == "" || font.Path == "" {
+			t.Errorf("font without family or path: %+v", font)
+		}
+	}
+	if want := len(generator.EmbeddedFonts()); embedded != want {
+		t.Errorf("%d embedded fonts, want %d", embedded, want)
+	}
+}
+
+func TestAdminVersionOverride(t *testing.T) {
+	defer func(version string) { Version = version }(Version)
+	Version = "1.2.3"
+
+	var info VersionInfo
+	rec := get(Handler(testOptions()), "/_imagen/version", nil)
+	if err := json.Unmarshal(rec.Body.Bytes(), &info); err != nil {
+		t.Fatalf("invalid JSON %q: %v", rec.Body.String(), err)
+	}
+	if info.Version != "1.2.3" {
+		t.Errorf("version = %q, want 1.2.3", info.Version)
+	}
+}
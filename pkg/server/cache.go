@@ -0,0 +1,116 @@
+package server
+
+import (
+	"container/list"
+	"sync"
+)
+
+// CacheStats holds the counters of the image cache
+type CacheStats struct {
+	Hits       int64 `json:"hits"`
+	Misses     int64 `json:"misses"`
+	Evictions  int64 `json:"evictions"`
+	Entries    int   `json:"entries"`
+	Bytes      int64 `json:"bytes"`
+	MaxEntries int   `json:"maxEntries"`
+	MaxBytes   int64 `json:"maxBytes"`
+}
+
+// Cache stores the encoded images of deterministic URLs, keyed by the canonical image definition.
+// Implementations must be safe for concurrent use.
+type Cache interface {
+	Get(key string) ([]byte, bool)
+	Put(key string, data []byte)
+}
+
+// LRUCache is a LRU cache of encoded images, bounded by total size and entry count
+type LRUCache struct {
+	mu         sync.Mutex
+	maxBytes   int64
+	maxEntries int
+	order      *list.List // front = most recently used
+	entries    map[string]*list.Element
+	stats      CacheStats
+}
+
+// cacheEntry is a single cached image
+type cacheEntry struct {
+	key  string
+	data []byte
+}
+
+// NewLRUCache creates a cache with the given limits
+func NewLRUCache(maxBytes int64, maxEntries int) *LRUCache {
+	return &LRUCache{
+		maxBytes:   maxBytes,
+		maxEntries: maxEntries,
+		order:      list.New(),
+		entries:    make(map[string]*list.Element),
+	}
+}
+
+// Get returns the cached image for the key, and marks it as recently used
+func (c *LRUCache) Get(key string) ([]byte, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	elem, ok := c.entries[key]
+	if !ok {
+		c.stats.Misses++
+		return nil, false
+	}
+	c.stats.Hits++
+	c.order.MoveToFront(elem)
+	return elem.Value.(*cacheEntry).data, true
+}
+
+// Put adds an image to the cache, evicting the least recently used images if needed.
+// Images larger than the whole cache are not stored.
+func (c *LRUCache) Put(key string, data []byte) {
+	size := int64(len(data))
+	if size > c.maxBytes {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if elem, ok := c.entries[key]; ok {
+		entry := elem.Value.(*cacheEntry)
+		c.stats.Bytes += size - int64(len(entry.data))
+		entry.data = data
+		c.order.MoveToFront(elem)
+	} else {
+		c.entries[key] = c.order.PushFront(&cacheEntry{key: key, data: data})
+		c.stats.Bytes += size
+	}
+
+	for c.stats.Bytes > c.maxBytes || len(c.entries) > c.maxEntries {
+		c.removeOldest()
+	}
+}
+
+// removeOldest evicts the least recently used entry
+func (c *LRUCache) removeOldest() {
+	elem := c.order.Back()
+	if elem == nil {
+		return
+	}
+	entry := elem.Value.(*cacheEntry)
+	c.order.Remove(elem)
+	delete(c.entries, entry.key)
+	c.stats.Bytes -= int64(len(entry.data))
+	c.stats.Evictions++
+}
+
+// Stats returns a snapshot of the cache counters
+func (c *LRUCache) Stats() CacheStats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	stats := c.stats
+	stats.Entries = len(c.entries)
+	stats.MaxEntries = c.maxEntries
+	stats.MaxBytes = c.maxBytes
+	return stats
+}
@@ -0,0 +1,126 @@
+package server
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestLRUCache(t *testing.T) {
+	data := func(size int) []byte { return []byte(strings.Repeat("x", size)) }
+
+	tests := []struct {
+		name       string
+		maxBytes   int64
+		maxEntries int
+		run        func(c *LRUCache)
+		wantKeys   []string // keys still in the cache
+		wantGone   []string // keys evicted or never stored
+		wantBytes  int64
+		wantEvicts int64
+	}{
+		{
+			name: "entry limit evicts the oldest", maxBytes: 1000, maxEntries: 2,
+			run: func(c *LRUCache) {
+				c.Put("a", data(10))
+				c.Put("b", data(10))
+				c.Put("c", data(10))
+			},
+			wantKeys: []string{"b", "c"}, wantGone: []string{"a"}, wantBytes: 20, wantEvicts: 1,
+		},
+		{
+			name: "get marks as recently used", maxBytes: 1000, maxEntries: 2,
+			run: func(c *LRUCache) {
+				c.Put("a", data(10))
+				c.Put("b", data(10))
+				c.Get("a")
+				c.Put("c", data(10))
+			},
+			wantKeys: []string{"a", "c"}, wantGone: []string{"b"}, wantBytes: 20, wantEvicts: 1,
+		},
+		{
+			name: "byte limit evicts until the new entry fits", maxBytes: 100, maxEntries: 10,
+			run: func(c *LRUCache) {
+				c.Put("a", data(40))
+				c.Put("b", data(40))
+				c.Put("c", data(61))
+			},
+			wantKeys: []string{"c"}, wantGone: []string{"a", "b"}, wantBytes: 61, wantEvicts: 2,
+		},
+		{
+			name: "entries larger than the cache are not stored", maxBytes: 100, maxEntries: 10,
+			run: func(c *LRUCache) {
+				c.Put("a", data(40))
+				c.Put("big", data(101))
+			},
+			wantKeys: []string{"a"}, wantGone: []string{"big"}, wantBytes: 40,
+		},
+		{
+			name: "replacing an entry updates the size", maxBytes: 100, maxEntries: 10,
+			run: func(c *LRUCache) {
+				c.Put("a", data(40))
+				c.Put("b", data(40))
+				c.Put("a", data(61))
+			},
+			wantKeys: []string{"a"}, wantGone: []string{"b"}, wantBytes: 61, wantEvicts: 1,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := NewLRUCache(tt.maxBytes, tt.maxEntries)
+			tt.run(c)
+
+			// Check the stats before the lookups below change the order and counters
+			stats := c.Stats()
+			if stats.Bytes != tt.wantBytes || stats.Entries != len(tt.wantKeys) || stats.Evictions != tt.wantEvicts {
+				t.Errorf("stats = %+v, want %d bytes, %d entries and %d evictions", stats, tt.wantBytes, len(tt.wantKeys), tt.wantEvicts)
+			}
+			for _, key := range tt.wantKeys {
+				if _, ok := c.Get(key); !ok {
+					t.Errorf("%s is not in the cache", key)
+				}
+			}
+			for _, key := range tt.wantGone {
+				if _, ok := c.Get(key); ok {
+					t.Errorf("%s is still in the cache", key)
+				}
+			}
+		})
+	}
+}
+
+func TestLRUCacheHitsAndMisses(t *testing.T) {
+	c := NewLRUCache(100, 10)
+	c.Put("a", []byte("data"))
+	if data, ok := c.Get("a"); !ok || string(data) != "data" {
+		t.Errorf("Get(a) = %q, %v, want data, true", data, ok)
+	}
+	c.Get("a")
+	c.Get("b")
+
+	stats := c.Stats()
+	if stats.Hits != 2 || stats.Misses != 1 {
+		t.Errorf("stats = %+v, want 2 hits and 1 miss", stats)
+	}
+	if stats.MaxBytes != 100 || stats.MaxEntries != 10 {
+		t.Errorf("stats = %+v, want the limits 100 bytes and 10 entries", stats)
+	}
+}
+
+func TestHandlerCache(t *testing.T) {
+	handler := Handler(testOptions())
+	tests := []struct {
+		target string
+		want   string // X-Cache header
+	}{
+		{"/100x50/c:red", "MISS"},
+		{"/100x50/c:red", "HIT"},
+		{"/100x50?colors=red", "HIT"},
+		{"/100x50/c:blue", "MISS"},
+		{"/100x50/c:random", ""},
+	}
+	for _, tt := range tests {
+		if got := get(handler, tt.target, nil).Header().Get("X-Cache"); got != tt.want {
+			t.Errorf("%s: X-Cache = %q, want %q", tt.target, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,31 @@
+package server
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"strings"
+)
+
+// Cache-Control values for deterministic and random images
+const (
+	cacheControlImmutable = "public, max-age=31536000, immutable"
+	cacheControlNoStore   = "no-store"
+)
+
+// computeETag returns a strong ETag for the canonical key of an image
+func computeETag(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return `"` + hex.EncodeToString(sum[:16]) + `"`
+}
+
+// etagMatches returns true if the If-None-Match header value matches the given ETag.
+// If-None-Match uses the weak comparison, so a "W/" prefix is ignored.
+func etagMatches(ifNoneMatch, etag string) bool {
+	for _, candidate := range strings.Split(ifNoneMatch, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" || strings.TrimPrefix(candidate, "W/") == etag {
+			return true
+		}
+	}
+	return false
+}
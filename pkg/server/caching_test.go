@@ -0,0 +1,90 @@
+package server
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestETag(t *testing.T) {
+	handler := Handler(testOptions())
+	etag := get(handler, "/100x50/c:red", nil).Header().Get("ETag")
+	if etag == "" {
+		t.Fatal("deterministic image has no ETag")
+	}
+
+	tests := []struct {
+		name             string
+		target           string
+		ifNoneMatch      string
+		wantStatus       int
+		wantETag         bool
+		wantCacheControl string
+	}{
+		{"deterministic", "/100x50/c:red", "", http.StatusOK, true, cacheControlImmutable},
+		{"same image in the query", "/100x50?colors=red", etag, http.StatusNotModified, true, cacheControlImmutable},
+		{"matching", "/100x50/c:red", etag, http.StatusNotModified, true, cacheControlImmutable},
+		{"weak match", "/100x50/c:red", "W/" + etag, http.StatusNotModified, true, cacheControlImmutable},
+		{"match in a list", "/100x50/c:red", `"other", ` + etag, http.StatusNotModified, true, cacheControlImmutable},
+		{"any", "/100x50/c:red", "*", http.StatusNotModified, true, cacheControlImmutable},
+		{"other image", "/100x50/c:blue", etag, http.StatusOK, true, cacheControlImmutable},
+		{"seeded random", "/100x50/c:random/s:42", "", http.StatusOK, true, cacheControlImmutable},
+		{"random", "/100x50/c:random", "", http.StatusOK, false, cacheControlNoStore},
+		{"random never matches", "/100x50/c:random", "*", http.StatusOK, false, cacheControlNoStore},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			header := http.Header{}
+			if tt.ifNoneMatch != "" {
+				header.Set("If-None-Match", tt.ifNoneMatch)
+			}
+			rec := get(handler, tt.target, header)
+
+			if rec.Code != tt.wantStatus {
+				t.Errorf("status = %d, want %d", rec.Code, tt.wantStatus)
+			}
+			if got := rec.Header().Get("ETag"); (got != "") != tt.wantETag {
+				t.Errorf("ETag = %q, want an ETag: %v", got, tt.wantETag)
+			}
+			if got := rec.Header().Get("Cache-Control"); got != tt.wantCacheControl {
+				t.Errorf("Cache-Control = %q, want %q", got, tt.wantCacheControl)
+			}
+			if rec.Code == http.StatusNotModified && rec.Body.Len() != 0 {
+				t.Errorf("304 response has a body of %d bytes", rec.Body.Len())
+			}
+		})
+	}
+}
+
+func TestETagDiffersPerImage(t *testing.T) {
+	handler := Handler(testOptions())
+	seen := make(map[string]string)
+	for _, target := range []string{"/100x50/c:red", "/100x50/c:blue", "/100x51/c:red", "/100x50/c:red/f:webp", "/100x50/c:random/s:1", "/100x50/c:random/s:2"} {
+		etag := get(handler, target, nil).Header().Get("ETag")
+		if other, ok := seen[etag]; ok {
+			t.Errorf("%s and %s have the same ETag %s", target, other, etag)
+		}
+		seen[etag] = target
+	}
+}
+
+func TestETagMatches(t *testing.T) {
+	const etag = `"abc"`
+	tests := []struct {
+		ifNoneMatch string
+		want        bool
+	}{
+		{`"abc"`, true},
+		{`W/"abc"`, true},
+		{`"x", "abc"`, true},
+		{`"x","abc"`, true},
+		{`*`, true},
+		{`"abcd"`, false},
+		{`abc`, false},
+		{``, false},
+	}
+	for _, tt := range tests {
+		if got := etagMatches(tt.ifNoneMatch, etag); got != tt.want {
+			t.Errorf("etagMatches(%q) = %v, want %v", tt.ifNoneMatch, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,233 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/bylexus/imagen/pkg/generator"
+	"github.com/bylexus/imagen/pkg/spec"
+)
+
+// imageHandler serves the images described by the request URLs
+type imageHandler struct {
+	options     Options
+	basePath    string // without trailing slash, empty for the root
+	logger      *slog.Logger
+	parser      *spec.Parser
+	cache       Cache          // nil if caching is disabled
+	renderSlots chan struct{}  // semaphore of the concurrent renders, nil if unlimited
+	renders     sync.WaitGroup // running renders, including the ones that timed out
+	metrics     *metrics
+
+	shuttingDown atomic.Bool // set on shutdown, so the readiness check fails
+	versionInfo  func() VersionInfo
+}
+
+// Handler returns an http.Handler that serves images (plus the admin endpoints below
+// /_imagen/ and the metrics at options.MetricsPath) with the given options.
+// Requests outside of options.BasePath get a 404, so the handler can be mounted
+// under a prefix of another server's ServeMux.
+func Handler(options Options) http.Handler {
+	return newImageHandler(options)
+}
+
+// newImageHandler creates the image handler, with the default logger, parser and cache if not given
+func newImageHandler(options Options) *imageHandler {
+	h := &imageHandler{
+		options:  options,
+		basePath: strings.TrimSuffix(options.BasePath, "/"),
+		logger:   options.Logger,
+		parser:   options.Parser,
+		cache:    options.Cache,
+		metrics:  newMetrics(),
+
+		versionInfo: sync.OnceValue(buildVersionInfo),
+	}
+	if h.basePath != "" && !strings.HasPrefix(h.basePath, "/") {
+		h.basePath = "/" + h.basePath
+	}
+	if h.parser == nil {
+		h.parser = spec.NewParser(nil)
+	}
+	if h.logger == nil {
+		h.logger = slog.Default()
+	}
+	if h.cache == nil && options.CacheMaxBytes > 0 && options.CacheMaxEntries > 0 {
+		h.cache = NewLRUCache(options.CacheMaxBytes, options.CacheMaxEntries)
+	}
+	if options.MaxConcurrentRenders > 0 {
+		h.renderSlots = make(chan struct{}, options.MaxConcurrentRenders)
+	}
+	return h
+}
+
+// ServeHTTP strips the base path and dispatches the request
+func (h *imageHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	start := time.Now()
+	rec := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+	var info requestInfo
+
+	path, ok := h.stripBasePath(r.URL.EscapedPath())
+	switch {
+	case !ok:
+		info.internal = true
+		http.NotFound(rec, r)
+	case strings.HasPrefix(path, adminPrefix):
+		info.internal = true
+		h.serveAdmin(rec, r, path)
+	case h.options.MetricsPath != "" && path == h.options.MetricsPath:
+		info.internal = true
+		h.serveMetrics(rec)
+	default:
+		h.serveImage(rec, r, path, &info)
+		h.metrics.observeRequest(rec.status, info.format, info.mode, rec.bytes)
+	}
+
+	h.logRequest(r, rec, &info, time.Since(start))
+}
+
+// stripBasePath removes the base path from the (escaped) request path.
+// It returns false if the path is outside of the base path.
+func (h *imageHandler) stripBasePath(path string) (string, bool) {
+	if h.basePath == "" {
+		return path, true
+	}
+	if path == h.basePath {
+		return "/", true
+	}
+	if rest, ok := strings.CutPrefix(path, h.basePath+"/"); ok {
+		return "/" + rest, true
+	}
+	return "", false
+}
+
+// serveImage generates the image described by the path and query string
+func (h *imageHandler) serveImage(w http.ResponseWriter, r *http.Request, path string, info *requestInfo) {
+	h.metrics.inFlight.Add(1)
+	defer h.metrics.inFlight.Add(-1)
+
+	imageSpec, config, err := parseURLConfig(h.parser, path, r.URL.RawQuery)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Invalid URL: %v", err), http.StatusBadRequest)
+		return
+	}
+	info.width = config.Width
+	info.height = config.Height
+	info.format = config.Format
+	info.mode = string(config.ColorMode)
+
+	// Reject images that exceed the configured limits, before anything is allocated
+	if limitErr := h.checkLimits(imageSpec); limitErr != nil {
+		http.Error(w, fmt.Sprintf("Limit exceeded: %v", limitErr), limitErr.status)
+		return
+	}
+
+	// Deterministic images can be cached forever, random images must not be cached at all
+	etag := ""
+	cacheControl := cacheControlNoStore
+	if imageSpec.IsDeterministic() {
+		etag = computeETag(imageSpec.CanonicalKey())
+		cacheControl = cacheControlImmutable
+
+		if etagMatches(r.Header.Get("If-None-Match"), etag) {
+			w.Header().Set("ETag", etag)
+			w.Header().Set("Cache-Control", cacheControl)
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+	}
+
+	// Deterministic images are served from the cache, if possible
+	var data []byte
+	cached := false
+	if etag != "" && h.cache != nil {
+		data, cached = h.cache.Get(imageSpec.CanonicalKey())
+		info.cache = "MISS"
+		if cached {
+			info.cache = "HIT"
+		}
+		w.Header().Set("X-Cache", info.cache)
+	}
+
+	if !cached {
+		// Generate image (or animation) into a buffer, so errors can still be reported
+		var timings generator.RenderTimings
+		data, timings, err = h.render(r.Context(), config)
+		info.render = timings.Render + timings.Encode
+		if errors.Is(err, context.Canceled) {
+			// The client went away, nobody reads the response
+			w.WriteHeader(statusClientClosedRequest)
+			return
+		}
+		if errors.Is(err, errServerBusy) || errors.Is(err, errRenderTimeout) {
+			w.Header().Set("Retry-After", "1")
+			http.Error(w, err.Error(), http.StatusServiceUnavailable)
+			return
+		}
+		if err != nil {
+			http.Error(w, fmt.Sprintf("Failed to generate image: %v", err), http.StatusInternalServerError)
+			return
+		}
+
+		if etag != "" && h.cache != nil {
+			h.cache.Put(imageSpec.CanonicalKey(), data)
+		}
+	}
+
+	// Set content type based on format
+	switch config.Format {
+	case "png":
+		w.Header().Set("Content-Type", "image/png")
+	case "jpeg", "jpg":
+		w.Header().Set("Content-Type", "image/jpeg")
+	case "webp":
+		w.Header().Set("Content-Type", "image/webp")
+	case "gif":
+		w.Header().Set("Content-Type", "image/gif")
+	case "apng":
+		w.Header().Set("Content-Type", "image/apng")
+	case "svg":
+		w.Header().Set("Content-Type", "image/svg+xml")
+	default:
+		w.Header().Set("Content-Type", "image/png")
+	}
+
+	if etag != "" {
+		w.Header().Set("ETag", etag)
+	}
+	w.Header().Set("Cache-Control", cacheControl)
+
+	// Write image
+	if _, err := w.Write(data); err != nil {
+		h.logger.Error("Failed to write image", "error", err)
+	}
+}
+
+// serveMetrics returns the metrics in the Prometheus text format
+func (h *imageHandler) serveMetrics(w http.ResponseWriter) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	w.Header().Set("Cache-Control", cacheControlNoStore)
+	h.metrics.writeTo(w, h.cache)
+}
+
+// parseURLConfig parses the URL path and query string and returns the spec and the ImageConfig
+// URL format: /[size]/p:[preset]/[c|g|r|k|t|n]:[color-config]/t:[text]/f:[format]/b:[border]/a:[animation]/s:[seed]?[query]
+// See the spec package for the full grammar.
+func parseURLConfig(parser *spec.Parser, path, rawQuery string) (*spec.Spec, *generator.ImageConfig, error) {
+	s, err := parser.ParseURL(path, rawQuery)
+	if err != nil {
+		return nil, nil, err
+	}
+	config, err := s.Config(s.Rand())
+	if err != nil {
+		return nil, nil, err
+	}
+	return s, config, nil
+}
@@ -0,0 +1,70 @@
+package server
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestStripBasePath(t *testing.T) {
+	tests := []struct {
+		basePath string
+		path     string
+		want     string
+		wantOK   bool
+	}{
+		{"", "/400x300", "/400x300", true},
+		{"", "/", "/", true},
+		{"/img", "/img/400x300", "/400x300", true},
+		{"/img", "/img", "/", true},
+		{"/img", "/img/", "/", true},
+		{"/img/", "/img/400x300", "/400x300", true},
+		{"img", "/img/400x300", "/400x300", true},
+		{"/img", "/images/400x300", "", false},
+		{"/img", "/400x300", "", false},
+		{"/a/b", "/a/b/400x300/c:red", "/400x300/c:red", true},
+		{"/a/b", "/a/400x300", "", false},
+	}
+	for _, tt := range tests {
+		options := testOptions()
+		options.BasePath = tt.basePath
+		h := newImageHandler(options)
+		got, ok := h.stripBasePath(tt.path)
+		if got != tt.want || ok != tt.wantOK {
+			t.Errorf("base path %q: stripBasePath(%q) = %q, %v, want %q, %v", tt.basePath, tt.path, got, ok, tt.want, tt.wantOK)
+		}
+	}
+}
+
+func TestBasePathRouting(t *testing.T) {
+	options := testOptions()
+	options.BasePath = "/placeholders"
+	options.MetricsPath = "/metrics"
+	handler := Handler(options)
+
+	tests := []struct {
+		target     string
+		wantStatus int
+		wantType   string
+	}{
+		{"/placeholders/400x300", http.StatusOK, "image/png"},
+		{"/placeholders/400x300/f:svg", http.StatusOK, "image/svg+xml"},
+		{"/placeholders/400x300?format=gif", http.StatusOK, "image/gif"},
+		{"/placeholders/_imagen/health", http.StatusOK, "application/json"},
+		{"/placeholders/metrics", http.StatusOK, "text/plain; version=0.0.4; charset=utf-8"},
+		{"/400x300", http.StatusNotFound, ""},
+		{"/_imagen/health", http.StatusNotFound, ""},
+		{"/metrics", http.StatusNotFound, ""},
+		{"/placeholdersx/400x300", http.StatusNotFound, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.target, func(t *testing.T) {
+			rec := get(handler, tt.target, nil)
+			if rec.Code != tt.wantStatus {
+				t.Errorf("status = %d, want %d", rec.Code, tt.wantStatus)
+			}
+			if tt.wantType != "" && rec.Header().Get("Content-Type") != tt.wantType {
+				t.Errorf("Content-Type = %q, want %q", rec.Header().Get("Content-Type"), tt.wantType)
+			}
+		})
+	}
+}
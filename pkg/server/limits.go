@@ -0,0 +1,144 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"unicode/utf8"
+
+	"github.com/bylexus/imagen/pkg/generator"
+	"github.com/bylexus/imagen/pkg/spec"
+)
+
+// Errors of the render limiter
+var (
+	errServerBusy    = errors.New("server busy, too many images are being rendered")
+	errRenderTimeout = errors.New("rendering the image took too long")
+)
+
+// statusClientClosedRequest is the status logged and counted for requests whose client
+// went away before the image was rendered (nginx's non-standard 499)
+const statusClientClosedRequest = 499
+
+// limitError is returned for requests that exceed a configured limit
+type limitError struct {
+	status  int
+	message string
+}
+
+// Error returns the limit violation message
+func (e *limitError) Error() string {
+	return e.message
+}
+
+// newLimitError creates a limit error with the given HTTP status code
+func newLimitError(status int, format string, args ...any) *limitError {
+	return &limitError{status: status, message: fmt.Sprintf(format, args...)}
+}
+
+// checkLimits checks the spec against the configured limits. A zero limit means unlimited.
+// Too large images are rejected with 413, other invalid values with 422.
+func (h *imageHandler) checkLimits(imageSpec *spec.Spec) *limitError {
+	opts := h.options
+
+	if opts.MaxWidth > 0 && imageSpec.Width > opts.MaxWidth {
+		return newLimitError(http.StatusRequestEntityTooLarge, "width %d exceeds the maximum of %d", imageSpec.Width, opts.MaxWidth)
+	}
+	if opts.MaxHeight > 0 && imageSpec.Height > opts.MaxHeight {
+		return newLimitError(http.StatusRequestEntityTooLarge, "height %d exceeds the maximum of %d", imageSpec.Height, opts.MaxHeight)
+	}
+	if opts.MaxFrames > 0 && imageSpec.Frames > opts.MaxFrames {
+		return newLimitError(http.StatusRequestEntityTooLarge, "%d frames exceed the maximum of %d", imageSpec.Frames, opts.MaxFrames)
+	}
+
+	// All frames of an animation are held in memory, so the pixel limit covers all of them
+	pixels := int64(imageSpec.Width) * int64(imageSpec.Height) * int64(imageSpec.Frames)
+	if opts.MaxPixels > 0 && pixels > opts.MaxPixels {
+		return newLimitError(http.StatusRequestEntityTooLarge, "%d pixels (width x height x frames) exceed the maximum of %d", pixels, opts.MaxPixels)
+	}
+
+	if length := utf8.RuneCountInString(imageSpec.Text); opts.MaxTextLength > 0 && length > opts.MaxTextLength {
+		return newLimitError(http.StatusUnprocessableEntity, "text length %d exceeds the maximum of %d", length, opts.MaxTextLength)
+	}
+	if opts.MaxTextSize > 0 && imageSpec.TextSize > opts.MaxTextSize {
+		return newLimitError(http.StatusUnprocessableEntity, "text size %g exceeds the maximum of %g", imageSpec.TextSize, opts.MaxTextSize)
+	}
+	if opts.MaxBorderWidth > 0 && imageSpec.BorderWidth > opts.MaxBorderWidth {
+		return newLimitError(http.StatusUnprocessableEntity, "border width %d exceeds the maximum of %d", imageSpec.BorderWidth, opts.MaxBorderWidth)
+	}
+
+	if opts.CheckLimits != nil {
+		if err := opts.CheckLimits(imageSpec); err != nil {
+			return newLimitError(http.StatusUnprocessableEntity, "%v", err)
+		}
+	}
+
+	return nil
+}
+
+// renderResult is the outcome of a render run
+type renderResult struct {
+	data    []byte
+	timings generator.RenderTimings
+	err     error
+}
+
+// render renders the image, limited by the number of concurrent renders and the render timeout.
+// A render that times out keeps its slot until it has finished, so runaway renders still count.
+func (h *imageHandler) render(ctx context.Context, config *generator.ImageConfig) ([]byte, generator.RenderTimings, error) {
+	if h.options.RenderTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, h.options.RenderTimeout)
+		defer cancel()
+	}
+
+	// Wait for a free render slot
+	if h.renderSlots != nil {
+		select {
+		case h.renderSlots <- struct{}{}:
+		case <-ctx.Done():
+			return nil, generator.RenderTimings{}, renderStopped(ctx, errServerBusy)
+		}
+	}
+
+	done := make(chan renderResult, 1)
+	h.renders.Add(1)
+	go func() {
+		defer h.renders.Done()
+		defer func() {
+			if h.renderSlots != nil {
+				<-h.renderSlots
+			}
+		}()
+		defer func() {
+			if r := recover(); r != nil {
+				done <- renderResult{err: fmt.Errorf("render panic: %v", r)}
+			}
+		}()
+
+		var buf bytes.Buffer
+		timings, err := generator.NewGenerator(config).RenderTimed(&buf)
+		if err == nil {
+			h.metrics.observeRender(timings.Render, timings.Encode)
+		}
+		done <- renderResult{data: buf.Bytes(), timings: timings, err: err}
+	}()
+
+	select {
+	case result := <-done:
+		return result.data, result.timings, result.err
+	case <-ctx.Done():
+		return nil, generator.RenderTimings{}, renderStopped(ctx, errRenderTimeout)
+	}
+}
+
+// renderStopped returns the error of a render that was stopped by its context: context.Canceled
+// if the client went away, otherwise the given limit error of the exceeded render timeout
+func renderStopped(ctx context.Context, limitErr error) error {
+	if ctx.Err() == context.Canceled {
+		return context.Canceled
+	}
+	return limitErr
+}
@@ -0,0 +1,143 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/bylexus/imagen/pkg/spec"
+)
+
+func TestLimitStatusCodes(t *testing.T) {
+	options := testOptions()
+	options.MaxWidth = 1000
+	options.MaxHeight = 800
+	options.MaxPixels = 500_000
+	options.MaxFrames = 5
+	options.MaxTextLength = 10
+	options.MaxTextSize = 100
+	options.MaxBorderWidth = 20
+	options.CheckLimits = func(s *spec.Spec) error {
+		if s.Format == "jpeg" {
+			return errors.New("no jpeg")
+		}
+		return nil
+	}
+	handler := Handler(options)
+
+	tests := []struct {
+		name       string
+		target     string
+		wantStatus int
+		wantBody   string
+	}{
+		{"within the limits", "/1000x500", http.StatusOK, ""},
+		{"width", "/1001x10", http.StatusRequestEntityTooLarge, "width 1001 exceeds the maximum of 1000"},
+		{"height", "/10x801", http.StatusRequestEntityTooLarge, "height 801 exceeds the maximum of 800"},
+		{"pixels", "/1000x501", http.StatusRequestEntityTooLarge, "501000 pixels"},
+		{"pixels of all frames", "/500x500/f:gif/a:3", http.StatusRequestEntityTooLarge, "750000 pixels"},
+		{"frames", "/10x10/f:gif/a:6", http.StatusRequestEntityTooLarge, "6 frames exceed the maximum of 5"},
+		{"text length", "/100x100/t:%22hello%20world%22", http.StatusUnprocessableEntity, "text length 11 exceeds the maximum of 10"},
+		{"text length in characters", "/100x100/t:%22%C3%A4%C3%B6%C3%BC%C3%A4%C3%B6%C3%BC%C3%A4%C3%B6%C3%BC%C3%A4%22", http.StatusOK, ""},
+		{"text size", "/100x100/t:%22a%22,s:101", http.StatusUnprocessableEntity, "text size 101 exceeds the maximum of 100"},
+		{"border width", "/100x100/b:21", http.StatusUnprocessableEntity, "border width 21 exceeds the maximum of 20"},
+		{"custom check", "/100x100/f:jpeg", http.StatusUnprocessableEntity, "no jpeg"},
+		{"invalid url", "/100x100/x:1", http.StatusBadRequest, "Invalid URL"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := get(handler, tt.target, nil)
+			if rec.Code != tt.wantStatus {
+				t.Errorf("status = %d, want %d: %s", rec.Code, tt.wantStatus, rec.Body.String())
+			}
+			if !strings.Contains(rec.Body.String(), tt.wantBody) {
+				t.Errorf("body = %q, want it to contain %q", rec.Body.String(), tt.wantBody)
+			}
+		})
+	}
+}
+
+func TestServerBusy(t *testing.T) {
+	options := testOptions()
+	options.MaxConcurrentRenders = 1
+	options.RenderTimeout = 50 * time.Millisecond
+	h := newImageHandler(options)
+
+	// All render slots are taken
+	h.renderSlots <- struct{}{}
+	defer func() { <-h.renderSlots }()
+
+	rec := get(h, "/100x100", nil)
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Errorf("status = %d, want %d", rec.Code, http.StatusServiceUnavailable)
+	}
+	if got := rec.Header().Get("Retry-After"); got != "1" {
+		t.Errorf("Retry-After = %q, want 1", got)
+	}
+}
+
+func TestClientGoneIsNoTimeout(t *testing.T) {
+	var logs bytes.Buffer
+	options := testOptions()
+	options.Logger = slog.New(slog.NewTextHandler(&logs, nil))
+	options.RenderTimeout = time.Minute
+	h := newImageHandler(options)
+
+	// The client is gone before the (large) image is rendered
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	req := httptest.NewRequest(http.MethodGet, "/3000x3000/g:red,blue", nil).WithContext(ctx)
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	h.renders.Wait()
+
+	if rec.Code != statusClientClosedRequest {
+		t.Errorf("status = %d, want %d", rec.Code, statusClientClosedRequest)
+	}
+	if rec.Header().Get("Retry-After") != "" || rec.Body.Len() > 0 {
+		t.Errorf("response has Retry-After %q and body %q, want neither", rec.Header().Get("Retry-After"), rec.Body.String())
+	}
+	if log := logs.String(); !strings.Contains(log, "level=INFO msg=request") || !strings.Contains(log, "status=499") {
+		t.Errorf("access log = %q, want an info entry with status 499", log)
+	}
+
+	var metrics bytes.Buffer
+	h.metrics.writeTo(&metrics, h.cache)
+	if !strings.Contains(metrics.String(), `imagen_requests_total{status="499"`) || strings.Contains(metrics.String(), `status="503"`) {
+		t.Errorf("metrics do not count the request as 499:\n%s", metrics.String())
+	}
+}
+
+func TestRenderStopped(t *testing.T) {
+	canceled, cancel := context.WithCancel(context.Background())
+	cancel()
+	timedOut, cancel := context.WithTimeout(context.Background(), 0)
+	defer cancel()
+	// A render timeout derived from a canceled request context
+	canceledWithTimeout, cancel := context.WithTimeout(canceled, time.Minute)
+	defer cancel()
+
+	tests := []struct {
+		name string
+		ctx  context.Context
+		want error
+	}{
+		{"client gone", canceled, context.Canceled},
+		{"client gone during the render timeout", canceledWithTimeout, context.Canceled},
+		{"render timeout", timedOut, errRenderTimeout},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			<-tt.ctx.Done()
+			if got := renderStopped(tt.ctx, errRenderTimeout); got != tt.want {
+				t.Errorf("renderStopped() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
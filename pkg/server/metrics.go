@@ -0,0 +1,194 @@
+package server
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// latencyBuckets are the upper bounds of the latency histograms, in seconds
+var latencyBuckets = []float64{0.001, 0.0025, 0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+
+// requestKey holds the labels of the request counter
+type requestKey struct {
+	status int
+	format string
+	mode   string
+}
+
+// metrics collects the server metrics, and writes them in the Prometheus text format
+type metrics struct {
+	mu       sync.Mutex
+	requests map[requestKey]uint64
+	render   *histogram
+	encode   *histogram
+
+	inFlight    atomic.Int64
+	bytesServed atomic.Uint64
+}
+
+// newMetrics creates an empty metrics collector
+func newMetrics() *metrics {
+	return &metrics{
+		requests: make(map[requestKey]uint64),
+		render:   newHistogram(latencyBuckets),
+		encode:   newHistogram(latencyBuckets),
+	}
+}
+
+// observeRequest counts a finished image request
+func (m *metrics) observeRequest(status int, format, mode string, bytes int64) {
+	if format == "" {
+		format = "unknown"
+	}
+	if mode == "" {
+		mode = "unknown"
+	}
+
+	m.mu.Lock()
+	m.requests[requestKey{status: status, format: format, mode: mode}]++
+	m.mu.Unlock()
+	m.bytesServed.Add(uint64(bytes))
+}
+
+// observeRender records the durations of a render run
+func (m *metrics) observeRender(render, encode time.Duration) {
+	m.render.observe(render.Seconds())
+	m.encode.observe(encode.Seconds())
+}
+
+// writeTo writes all metrics in the Prometheus text exposition format
+func (m *metrics) writeTo(w io.Writer, cache Cache) {
+	m.mu.Lock()
+	keys := make([]requestKey, 0, len(m.requests))
+	for key := range m.requests {
+		keys = append(keys, key)
+	}
+	counts := make(map[requestKey]uint64, len(m.requests))
+	for key, count := range m.requests {
+		counts[key] = count
+	}
+	m.mu.Unlock()
+
+	sort.Slice(keys, func(i, j int) bool {
+		a, b := keys[i], keys[j]
+		if a.status != b.status {
+			return a.status < b.status
+		}
+		if a.format != b.format {
+			return a.format < b.format
+		}
+		return a.mode < b.mode
+	})
+
+	writeMetricHeader(w, "imagen_requests_total", "counter", "Image requests by status code, format and color mode.")
+	for _, key := range keys {
+		fmt.Fprintf(w, "imagen_requests_total{status=\"%d\",format=%s,mode=%s} %d\n",
+			key.status, quoteLabel(key.format), quoteLabel(key.mode), counts[key])
+	}
+
+	writeMetricHeader(w, "imagen_requests_in_flight", "gauge", "Image requests currently being served.")
+	fmt.Fprintf(w, "imagen_requests_in_flight %d\n", m.inFlight.Load())
+
+	writeMetricHeader(w, "imagen_response_bytes_total", "counter", "Bytes of response bodies served for image requests.")
+	fmt.Fprintf(w, "imagen_response_bytes_total %d\n", m.bytesServed.Load())
+
+	m.render.writeTo(w, "imagen_render_duration_seconds", "Time spent drawing images.")
+	m.encode.writeTo(w, "imagen_encode_duration_seconds", "Time spent encoding images.")
+
+	if cache, ok := cache.(interface{ Stats() CacheStats }); ok {
+		stats := cache.Stats()
+		writeMetricHeader(w, "imagen_cache_hits_total", "counter", "Image cache hits.")
+		fmt.Fprintf(w, "imagen_cache_hits_total %d\n", stats.Hits)
+		writeMetricHeader(w, "imagen_cache_misses_total", "counter", "Image cache misses.")
+		fmt.Fprintf(w, "imagen_cache_misses_total %d\n", stats.Misses)
+		writeMetricHeader(w, "imagen_cache_evictions_total", "counter", "Images evicted from the cache.")
+		fmt.Fprintf(w, "imagen_cache_evictions_total %d\n", stats.Evictions)
+		writeMetricHeader(w, "imagen_cache_entries", "gauge", "Images in the cache.")
+		fmt.Fprintf(w, "imagen_cache_entries %d\n", stats.Entries)
+		writeMetricHeader(w, "imagen_cache_bytes", "gauge", "Size of the images in the cache.")
+		fmt.Fprintf(w, "imagen_cache_bytes %d\n", stats.Bytes)
+	}
+}
+
+// histogram is a cumulative Prometheus histogram with fixed buckets
+type histogram struct {
+	mu      sync.Mutex
+	buckets []float64
+	counts  []uint64 // per bucket, not cumulative
+	sum     float64
+	count   uint64
+}
+
+// newHistogram creates a histogram with the given bucket upper bounds
+func newHistogram(buckets []float64) *histogram {
+	return &histogram{buckets: buckets, counts: make([]uint64, len(buckets))}
+}
+
+// observe adds a value to the histogram
+func (h *histogram) observe(v float64) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	for i, bound := range h.buckets {
+		if v <= bound {
+			h.counts[i]++
+			break
+		}
+	}
+	h.sum += v
+	h.count++
+}
+
+// writeTo writes the histogram with cumulative bucket counts
+func (h *histogram) writeTo(w io.Writer, name, help string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	writeMetricHeader(w, name, "histogram", help)
+	var cumulative uint64
+	for i, bound := range h.buckets {
+		cumulative += h.counts[i]
+		fmt.Fprintf(w, "%s_bucket{le=\"%s\"} %d\n", name, strconv.FormatFloat(bound, 'g', -1, 64), cumulative)
+	}
+	fmt.Fprintf(w, "%s_bucket{le=\"+Inf\"} %d\n", name, h.count)
+	fmt.Fprintf(w, "%s_sum %s\n", name, strconv.FormatFloat(h.sum, 'g', -1, 64))
+	fmt.Fprintf(w, "%s_count %d\n", name, h.count)
+}
+
+// writeMetricHeader writes the HELP and TYPE lines of a metric
+func writeMetricHeader(w io.Writer, name, typ, help string) {
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", name, help, name, typ)
+}
+
+// quoteLabel quotes a label value, escaping backslashes, quotes and newlines
+func quoteLabel(v string) string {
+	v = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(v)
+	return `"` + v + `"`
+}
+
+// statusRecorder records the status code and body size of a response
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+	bytes  int64
+}
+
+// WriteHeader records the status code
+func (r *statusRecorder) WriteHeader(status int) {
+	r.status = status
+	r.ResponseWriter.WriteHeader(status)
+}
+
+// Write records the body size
+func (r *statusRecorder) Write(b []byte) (int, error) {
+	n, err := r.ResponseWriter.Write(b)
+	r.bytes += int64(n)
+	return n, err
+}
@@ -0,0 +1,110 @@
+package server
+
+import (
+	"regexp"
+	"strings"
+	"testing"
+)
+
+// sampleLine matches a sample of the Prometheus text format, e.g. `name{label="value"} 1`
+var sampleLine = regexp.MustCompile(`^([a-z_]+)(\{[a-z]+="(?:[^"\\]|\\.)*"(?:,[a-z]+="(?:[^"\\]|\\.)*")*\})? [0-9.e+-]+$`)
+
+func TestMetricsFormat(t *testing.T) {
+	options := testOptions()
+	options.MetricsPath = "/metrics"
+	handler := Handler(options)
+
+	for _, target := range []string{"/100x50", "/100x50", "/100x50/f:gif", "/100x50/x:1", "/_imagen/health"} {
+		get(handler, target, nil)
+	}
+	rec := get(handler, "/metrics", nil)
+	if got := rec.Header().Get("Content-Type"); got != "text/plain; version=0.0.4; charset=utf-8" {
+		t.Errorf("Content-Type = %q", got)
+	}
+	body := rec.Body.String()
+
+	// Every sample follows the HELP and TYPE lines of its metric
+	typed := make(map[string]string)
+	for _, line := range strings.Split(strings.TrimSuffix(body, "\n"), "\n") {
+		if rest, ok := strings.CutPrefix(line, "# TYPE "); ok {
+			name, typ, _ := strings.Cut(rest, " ")
+			typed[name] = typ
+			continue
+		}
+		if strings.HasPrefix(line, "# HELP ") {
+			continue
+		}
+		match := sampleLine.FindStringSubmatch(line)
+		if match == nil {
+			t.Errorf("invalid sample line %q", line)
+			continue
+		}
+		name := match[1]
+		if typed[name] == "" {
+			base := name
+			for _, suffix := range []string{"_bucket", "_sum", "_count"} {
+				base = strings.TrimSuffix(base, suffix)
+			}
+			if typed[base] != "histogram" {
+				t.Errorf("sample %s without a TYPE line", name)
+			}
+		}
+	}
+
+	for _, want := range []string{
+		`imagen_requests_total{status="200",format="png",mode="solid"} 2`,
+		`imagen_requests_total{status="200",format="gif",mode="solid"} 1`,
+		`imagen_requests_total{status="400",format="unknown",mode="unknown"} 1`,
+		`imagen_requests_in_flight 0`,
+		`imagen_render_duration_seconds_count 2`,
+		`imagen_encode_duration_seconds_bucket{le="+Inf"} 2`,
+		`imagen_cache_hits_total 1`,
+		`imagen_cache_misses_total 2`,
+		`imagen_cache_entries 2`,
+	} {
+		if !strings.Contains(body, want+"\n") {
+			t.Errorf("metrics do not contain %s:\n%s", want, body)
+		}
+	}
+	if strings.Contains(body, "health") {
+		t.Error("admin requests are counted as image requests")
+	}
+}
+
+func TestHistogram(t *testing.T) {
+	h := newHistogram([]float64{0.1, 1})
+	for _, v := range []float64{0.05, 0.1, 0.5, 3} {
+		h.observe(v)
+	}
+
+	var b strings.Builder
+	h.writeTo(&b, "test_seconds", "Test durations.")
+	want := `# HELP test_seconds Test durations.
+# TYPE test_seconds histogram
+test_seconds_bucket{le="0.1"} 2
+test_seconds_bucket{le="1"} 3
+test_seconds_bucket{le="+Inf"} 4
+test_seconds_sum 3.65
+test_seconds_count 4
+`
+	if b.String() != want {
+		t.Errorf("histogram =\n%s\nwant\n%s", b.String(), want)
+	}
+}
+
+func TestQuoteLabel(t *testing.T) {
+	tests := []struct {
+		value string
+		want  string
+	}{
+		{"png", `"png"`},
+		{`a"b`, `"a\"b"`},
+		{`a\b`, `"a\\b"`},
+		{"a\nb", `"a\nb"`},
+	}
+	for _, tt := range tests {
+		if got := quoteLabel(tt.value); got != tt.want {
+			t.Errorf("quoteLabel(%q) = %s, want %s", tt.value, got, tt.want)
+		}
+	}
+}
@@ -1,539 +1,300 @@
 package server
 
 import (
+	"context"
+	"crypto/tls"
+	"errors"
 	"fmt"
-	"image/color"
-	"log"
-	"math/rand"
+	"log/slog"
+	"net"
 	"net/http"
-	"strconv"
-	"strings"
+	"runtime"
+	"sync"
+	"time"
 
-	"github.com/bylexus/imagen/pkg/generator"
+	"github.com/bylexus/imagen/pkg/spec"
 )
 
-// Server represents the HTTP server for serving images
-type Server struct {
-	addresses []string
+// Options holds the server and handler settings
+type Options struct {
+	BasePath    string       // path prefix to strip from the request paths, e.g. "/placeholders"
+	MetricsPath string       // path of the Prometheus metrics, relative to BasePath; empty disables them
+	Logger      *slog.Logger // server and access logs, nil means slog.Default()
+	Parser      *spec.Parser // URL parser with the image defaults and presets, nil means the built-in defaults
+
+	Cache           Cache // image cache, nil means a LRU cache with the limits below
+	CacheMaxBytes   int64 // maximum total size of the cached images, 0 disables the cache
+	CacheMaxEntries int   // maximum number of cached images, 0 disables the cache
+
+	// Request limits, 0 means unlimited
+	MaxWidth       int
+	MaxHeight      int
+	MaxPixels      int64   // width x height x frames
+	MaxTextLength  int     // in characters
+	MaxTextSize    float64 // in pt, glyphs are rasterized in this size
+	MaxBorderWidth int
+	MaxFrames      int
+
+	// CheckLimits is an optional additional check of the requested image, called after the
+	// limits above. A returned error is answered with 422 Unprocessable Entity.
+	CheckLimits func(*spec.Spec) error
+
+	MaxConcurrentRenders int           // 0 means unlimited
+	RenderTimeout        time.Duration // 0 means no timeout
+
+	// TLS certificate for the listen addresses with "https://" (or without scheme, if set)
+	TLSCertFile   string
+	TLSKeyFile    string
+	TLSSelfSigned bool // create a self-signed certificate for localhost on start
+
+	// HTTP server timeouts, 0 means no timeout
+	ReadTimeout     time.Duration
+	WriteTimeout    time.Duration
+	IdleTimeout     time.Duration
+	ShutdownTimeout time.Duration // graceful shutdown when the start context is canceled
 }
 
-// NewServer creates a new server with the given listen addresses
-func NewServer(addresses []string) *Server {
-	if len(addresses) == 0 {
-		addresses = []string{":3000"}
-	}
-	return &Server{addresses: addresses}
-}
+// DefaultOptions returns the default server settings
+func DefaultOptions() Options {
+	return Options{
+		MetricsPath: "/metrics",
 
-// Start starts the HTTP server
-func (s *Server) Start() error {
-	http.HandleFunc("/", s.handleImageRequest)
+		CacheMaxBytes:   64 << 20,
+		CacheMaxEntries: 1000,
 
-	// Start listeners
-	errChan := make(chan error, len(s.addresses))
+		MaxWidth:       8192,
+		MaxHeight:      8192,
+		MaxPixels:      40_000_000,
+		MaxTextLength:  500,
+		MaxTextSize:    1000,
+		MaxBorderWidth: 1000,
+		MaxFrames:      100,
 
-	for _, addr := range s.addresses {
-		addr := addr // capture for goroutine
-		go func() {
-			log.Printf("Starting server on %s", addr)
-			if err := http.ListenAndServe(addr, nil); err != nil {
-				errChan <- fmt.Errorf("failed to start server on %s: %w", addr, err)
-			}
-		}()
-	}
-
-	// Wait for any error
-	return <-errChan
-}
-
-// handleImageRequest handles HTTP requests and generates images based on URL parameters
-func (s *Server) handleImageRequest(w http.ResponseWriter, r *http.Request) {
-	config, err := parseURLConfig(r.URL.Path)
-	if err != nil {
-		http.Error(w, fmt.Sprintf("Invalid URL: %v", err), http.StatusBadRequest)
-		return
-	}
-
-	// Generate image
-	gen := generator.NewGenerator(config)
-	img, err := gen.Generate()
-	if err != nil {
-		http.Error(w, fmt.Sprintf("Failed to generate image: %v", err), http.StatusInternalServerError)
-		return
-	}
-
-	// Set content type based on format
-	switch config.Format {
-	case "png":
-		w.Header().Set("Content-Type", "image/png")
-	case "jpeg", "jpg":
-		w.Header().Set("Content-Type", "image/jpeg")
-	default:
-		w.Header().Set("Content-Type", "image/png")
-	}
+		MaxConcurrentRenders: runtime.NumCPU(),
+		RenderTimeout:        30 * time.Second,
 
-	// Write image
-	if err := gen.WriteImage(w, img); err != nil {
-		log.Printf("Failed to write image: %v", err)
+		ReadTimeout:     10 * time.Second,
+		WriteTimeout:    60 * time.Second,
+		IdleTimeout:     120 * time.Second,
+		ShutdownTimeout: 30 * time.Second,
 	}
 }
 
-// ColorDefinition holds a parsed background color configuration
-type ColorDefinition struct {
-	Mode      generator.ColorMode
-	Colors    []color.Color
-	Angle     float64
-	TileSize  int
-	TextColor *color.Color
+// Server represents the HTTP server for serving images
+type Server struct {
+	addresses []string
+	options   Options
+	handler   *imageHandler
+	mux       *http.ServeMux
+
+	mu        sync.Mutex
+	servers   []*http.Server
+	listeners []net.Listener
+	done      chan struct{} // closed when all servers have stopped
+	stopped   chan struct{} // closed when a shutdown has finished, including running renders
+	stopOnce  sync.Once
+	serveErr  error // first error of a server that stopped unexpectedly
 }
 
-// parseURLConfig parses the URL path and returns an ImageConfig
-// URL format: /[size]/[c|g|t|n]:[color-config]/t:[text]/f:[format]/b:[border]
-func parseURLConfig(path string) (*generator.ImageConfig, error) {
-	config := generator.DefaultConfig()
-
-	// Remove leading slash
-	path = strings.TrimPrefix(path, "/")
-
-	// Empty path means default image
-	if path == "" {
-		return config, nil
-	}
-
-	// Split by /
-	parts := strings.Split(path, "/")
-
-	// Collect all color definitions for random selection
-	var colorDefs []ColorDefinition
-
-	for i, part := range parts {
-		if part == "" {
-			continue
-		}
-
-		// First part without prefix is size
-		if i == 0 && !strings.Contains(part, ":") {
-			width, height, err := parseSize(part)
-			if err != nil {
-				return nil, fmt.Errorf("invalid size: %w", err)
-			}
-			config.Width = width
-			config.Height = height
-			continue
-		}
-
-		// Parse prefixed parameters
-		if len(part) < 2 || part[1] != ':' {
-			return nil, fmt.Errorf("invalid parameter format: %s", part)
-		}
-
-		prefix := part[0]
-		value := part[2:]
-
-		switch prefix {
-		case 'c': // solid color background
-			colorDef, err := parseSolidBackground(value)
-			if err != nil {
-				return nil, fmt.Errorf("invalid solid background: %w", err)
-			}
-			colorDefs = append(colorDefs, colorDef)
-		case 'g': // gradient background
-			colorDef, err := parseGradientBackground(value)
-			if err != nil {
-				return nil, fmt.Errorf("invalid gradient background: %w", err)
-			}
-			colorDefs = append(colorDefs, colorDef)
-		case 't': // tiled background OR text
-			// Determine if this is a tiled background or text
-			// Text starts with a quote, tiled starts with a color
-			if strings.HasPrefix(value, "\"") {
-				// This is text
-				if err := parseTextConfig(config, value); err != nil {
-					return nil, fmt.Errorf("invalid text config: %w", err)
-				}
-			} else {
-				// This is tiled background
-				colorDef, err := parseTiledBackground(value)
-				if err != nil {
-					return nil, fmt.Errorf("invalid tiled background: %w", err)
-				}
-				colorDefs = append(colorDefs, colorDef)
-			}
-		case 'n': // noise background
-			colorDef, err := parseNoiseBackground(value)
-			if err != nil {
-				return nil, fmt.Errorf("invalid noise background: %w", err)
-			}
-			colorDefs = append(colorDefs, colorDef)
-		case 'f': // format
-			config.Format = value
-		case 'b': // border
-			if err := parseBorderConfig(config, value); err != nil {
-				return nil, fmt.Errorf("invalid border config: %w", err)
-			}
-		default:
-			return nil, fmt.Errorf("unknown parameter prefix: %c", prefix)
-		}
+// NewServer creates a new server with the given listen addresses and options
+func NewServer(addresses []string, options Options) *Server {
+	if len(addresses) == 0 {
+		addresses = []string{":3000"}
 	}
+	handler := newImageHandler(options)
+	mux := http.NewServeMux()
+	mux.Handle("/", handler)
 
-	// If we have color definitions, randomly select one
-	if len(colorDefs) > 0 {
-		selectedDef := colorDefs[rand.Intn(len(colorDefs))]
-		config.ColorMode = selectedDef.Mode
-		config.Colors = selectedDef.Colors
-		config.GradientAngle = selectedDef.Angle
-		config.TileSize = selectedDef.TileSize
-		if selectedDef.TextColor != nil {
-			config.TextColor = selectedDef.TextColor
-		}
+	return &Server{
+		addresses: addresses,
+		options:   options,
+		handler:   handler,
+		mux:       mux,
 	}
-
-	return config, nil
 }
 
-// parseSolidBackground parses solid color background
-// Format: c:[color][:t:[textcolor]]
-func parseSolidBackground(value string) (ColorDefinition, error) {
-	def := ColorDefinition{
-		Mode:     generator.ColorModeSolid,
-		Colors:   []color.Color{},
-		TileSize: 16,
-	}
+// Start opens all listen addresses and serves requests in the background. If an address
+// cannot be opened, no listener is started and an error is returned. The server is shut
+// down gracefully when ctx is canceled, when Shutdown is called, or when a listener fails.
+func (s *Server) Start(ctx context.Context) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
 
-	// Split by : to separate color from optional text color
-	parts := strings.Split(value, ":t:")
-	if len(parts) > 2 {
-		return def, fmt.Errorf("invalid solid background format")
+	if s.done != nil {
+		return errors.New("server already started")
 	}
 
-	// Parse the main color
-	col, err := generator.ParseColor(parts[0])
-	if err != nil {
-		return def, fmt.Errorf("invalid color: %w", err)
-	}
-	def.Colors = append(def.Colors, col)
-
-	// Parse optional text color
-	if len(parts) == 2 {
-		textCol, err := generator.ParseColor(parts[1])
-		if err != nil {
-			return def, fmt.Errorf("invalid text color: %w", err)
+	// Load or create the TLS certificate, if any address uses TLS
+	addresses := make([]listenAddress, len(s.addresses))
+	var tlsConfig *tls.Config
+	for i, addr := range s.addresses {
+		var err error
+		if addresses[i], err = parseListenAddress(addr, s.options.hasTLSCertificate()); err != nil {
+			return fmt.Errorf("failed to start server: %w", err)
 		}
-		def.TextColor = &textCol
-	}
-
-	return def, nil
-}
-
-// parseGradientBackground parses gradient background
-// Format: g:[color1],[color2][,[color3]...][:angle][:t:[textcolor]]
-func parseGradientBackground(value string) (ColorDefinition, error) {
-	def := ColorDefinition{
-		Mode:     generator.ColorModeGradient,
-		Colors:   []color.Color{},
-		Angle:    0,
-		TileSize: 16,
-	}
-
-	// Split by :t: to separate main config from optional text color
-	parts := strings.Split(value, ":t:")
-	if len(parts) > 2 {
-		return def, fmt.Errorf("invalid gradient background format")
-	}
-
-	mainPart := parts[0]
-
-	// Parse optional text color
-	if len(parts) == 2 {
-		textCol, err := generator.ParseColor(parts[1])
-		if err != nil {
-			return def, fmt.Errorf("invalid text color: %w", err)
+		if addresses[i].useTLS && tlsConfig == nil {
+			if tlsConfig, err = s.options.tlsConfig(); err != nil {
+				return fmt.Errorf("failed to start server on %s: %w", addr, err)
+			}
+			if s.options.TLSSelfSigned {
+				s.handler.logger.Info("Using self-signed certificate",
+					"sha256", certificateFingerprint(tlsConfig.Certificates[0]))
+			}
 		}
-		def.TextColor = &textCol
-	}
-
-	// Split main part by : to separate colors from optional angle
-	colorAndAngle := strings.Split(mainPart, ":")
-	if len(colorAndAngle) > 2 {
-		return def, fmt.Errorf("invalid gradient format")
-	}
-
-	// Parse colors (comma-separated)
-	colorsPart := colorAndAngle[0]
-	colorStrs := strings.Split(colorsPart, ",")
-	if len(colorStrs) < 2 {
-		return def, fmt.Errorf("gradient requires at least 2 colors")
 	}
 
-	for _, colorStr := range colorStrs {
-		col, err := generator.ParseColor(strings.TrimSpace(colorStr))
+	// Open all listeners first, so a failing address does not leave the others running
+	for _, addr := range addresses {
+		ln, err := net.Listen("tcp", addr.addr)
 		if err != nil {
-			return def, fmt.Errorf("invalid color %s: %w", colorStr, err)
+			for _, opened := range s.listeners {
+				opened.Close()
+			}
+			s.listeners = nil
+			return fmt.Errorf("failed to start server on %s: %w", addr.addr, err)
 		}
-		def.Colors = append(def.Colors, col)
-	}
-
-	// Parse optional angle
-	if len(colorAndAngle) == 2 {
-		angle, err := strconv.ParseFloat(colorAndAngle[1], 64)
-		if err != nil {
-			return def, fmt.Errorf("invalid angle: %w", err)
+		s.listeners = append(s.listeners, ln)
+	}
+
+	s.done = make(chan struct{})
+	s.stopped = make(chan struct{})
+	var wg sync.WaitGroup
+	for i, ln := range s.listeners {
+		srv := &http.Server{
+			Handler:      s.mux,
+			ErrorLog:     slog.NewLogLogger(s.handler.logger.Handler(), slog.LevelError),
+			ReadTimeout:  s.options.ReadTimeout,
+			WriteTimeout: s.options.WriteTimeout,
+			IdleTimeout:  s.options.IdleTimeout,
 		}
-		def.Angle = angle
-	}
-
-	return def, nil
-}
-
-// parseTiledBackground parses tiled background
-// Format: t:[color1],[color2][,[color3]...][:tilesize][:t:[textcolor]]
-func parseTiledBackground(value string) (ColorDefinition, error) {
-	def := ColorDefinition{
-		Mode:     generator.ColorModeTiled,
-		Colors:   []color.Color{},
-		TileSize: 36, // default from README
-	}
+		s.servers = append(s.servers, srv)
 
-	// Split by :t: to separate main config from optional text color
-	parts := strings.Split(value, ":t:")
-	if len(parts) > 2 {
-		return def, fmt.Errorf("invalid tiled background format")
-	}
-
-	mainPart := parts[0]
-
-	// Parse optional text color
-	if len(parts) == 2 {
-		textCol, err := generator.ParseColor(parts[1])
-		if err != nil {
-			return def, fmt.Errorf("invalid text color: %w", err)
+		scheme := "http"
+		if addresses[i].useTLS {
+			// HTTP/2 is negotiated automatically on TLS connections
+			srv.TLSConfig = tlsConfig
+			scheme = "https"
 		}
-		def.TextColor = &textCol
-	}
 
-	// Split main part by : to separate colors from optional tile size
-	colorAndSize := strings.Split(mainPart, ":")
-	if len(colorAndSize) > 2 {
-		return def, fmt.Errorf("invalid tiled format")
-	}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			s.handler.logger.Info("Starting server", "address", fmt.Sprintf("%s://%s", scheme, ln.Addr()))
 
-	// Parse colors (comma-separated)
-	colorsPart := colorAndSize[0]
-	colorStrs := strings.Split(colorsPart, ",")
-	if len(colorStrs) < 2 {
-		return def, fmt.Errorf("tiled requires at least 2 colors")
-	}
+			var err error
+			if srv.TLSConfig != nil {
+				err = srv.ServeTLS(ln, "", "")
+			} else {
+				err = srv.Serve(ln)
+			}
+			if err != nil && !errors.Is(err, http.ErrServerClosed) {
+				s.handler.logger.Error("Server failed", "address", ln.Addr().String(), "error", err)
+				s.mu.Lock()
+				if s.serveErr == nil {
+					s.serveErr = fmt.Errorf("server on %s failed: %w", ln.Addr(), err)
+				}
+				s.mu.Unlock()
 
-	for _, colorStr := range colorStrs {
-		col, err := generator.ParseColor(strings.TrimSpace(colorStr))
-		if err != nil {
-			return def, fmt.Errorf("invalid color %s: %w", colorStr, err)
-		}
-		def.Colors = append(def.Colors, col)
+				// Do not leave the other listeners running
+				go s.shutdownWithTimeout()
+			}
+		}()
 	}
-
-	// Parse optional tile size
-	if len(colorAndSize) == 2 {
-		size, err := strconv.Atoi(colorAndSize[1])
-		if err != nil {
-			return def, fmt.Errorf("invalid tile size: %w", err)
+	go func() {
+		wg.Wait()
+		close(s.done)
+	}()
+
+	// Shut down when the context is canceled
+	go func() {
+		select {
+		case <-ctx.Done():
+			s.handler.logger.Info("Shutting down server")
+			s.shutdownWithTimeout()
+		case <-s.done:
 		}
-		def.TileSize = size
-	}
+	}()
 
-	return def, nil
+	return nil
 }
 
-// parseNoiseBackground parses noise background
-// Format: n:[color1],[color2][,[color3]...][:tilesize][:t:[textcolor]]
-func parseNoiseBackground(value string) (ColorDefinition, error) {
-	def := ColorDefinition{
-		Mode:     generator.ColorModeNoise,
-		Colors:   []color.Color{},
-		TileSize: 36, // default from README
-	}
-
-	// Split by :t: to separate main config from optional text color
-	parts := strings.Split(value, ":t:")
-	if len(parts) > 2 {
-		return def, fmt.Errorf("invalid noise background format")
-	}
+// Shutdown gracefully stops all listeners, and waits for in-flight requests and renders
+// to finish, or until ctx is done.
+func (s *Server) Shutdown(ctx context.Context) error {
+	s.handler.shuttingDown.Store(true)
 
-	mainPart := parts[0]
+	s.mu.Lock()
+	servers := s.servers
+	stopped := s.stopped
+	s.mu.Unlock()
 
-	// Parse optional text color
-	if len(parts) == 2 {
-		textCol, err := generator.ParseColor(parts[1])
-		if err != nil {
-			return def, fmt.Errorf("invalid text color: %w", err)
+	var errs []error
+	for _, srv := range servers {
+		if err := srv.Shutdown(ctx); err != nil {
+			errs = append(errs, err)
 		}
-		def.TextColor = &textCol
 	}
 
-	// Split main part by : to separate colors from optional tile size
-	colorAndSize := strings.Split(mainPart, ":")
-	if len(colorAndSize) > 2 {
-		return def, fmt.Errorf("invalid noise format")
+	// Renders that timed out may still be running
+	drained := make(chan struct{})
+	go func() {
+		s.handler.renders.Wait()
+		close(drained)
+	}()
+	select {
+	case <-drained:
+	case <-ctx.Done():
+		errs = append(errs, fmt.Errorf("waiting for running renders: %w", ctx.Err()))
 	}
 
-	// Parse colors (comma-separated)
-	colorsPart := colorAndSize[0]
-	colorStrs := strings.Split(colorsPart, ",")
-	if len(colorStrs) < 2 {
-		return def, fmt.Errorf("noise requires at least 2 colors")
+	// Let Wait return, now that the requests and renders are finished
+	if stopped != nil {
+		s.stopOnce.Do(func() { close(stopped) })
 	}
-
-	for _, colorStr := range colorStrs {
-		col, err := generator.ParseColor(strings.TrimSpace(colorStr))
-		if err != nil {
-			return def, fmt.Errorf("invalid color %s: %w", colorStr, err)
-		}
-		def.Colors = append(def.Colors, col)
-	}
-
-	// Parse optional tile size
-	if len(colorAndSize) == 2 {
-		size, err := strconv.Atoi(colorAndSize[1])
-		if err != nil {
-			return def, fmt.Errorf("invalid tile size: %w", err)
-		}
-		def.TileSize = size
-	}
-
-	return def, nil
+	return errors.Join(errs...)
 }
 
-// parseTextConfig parses text configuration
-// Format: t:"text"[,s:size][,c:color][,a:angle]
-func parseTextConfig(config *generator.ImageConfig, value string) error {
-	// Split by comma while respecting quotes
-	parts := splitRespectingQuotes(value)
-	if len(parts) == 0 {
-		return fmt.Errorf("empty text config")
+// shutdownWithTimeout shuts the server down, limited by the configured shutdown timeout
+func (s *Server) shutdownWithTimeout() {
+	ctx := context.Background()
+	if s.options.ShutdownTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, s.options.ShutdownTimeout)
+		defer cancel()
 	}
-
-	// First part is the text (remove quotes if present)
-	config.Text = strings.Trim(parts[0], "\"")
-
-	// Parse remaining parts
-	for _, part := range parts[1:] {
-		part = strings.TrimSpace(part)
-		if part == "" {
-			continue
-		}
-
-		if len(part) < 2 || part[1] != ':' {
-			return fmt.Errorf("invalid text parameter: %s", part)
-		}
-
-		prefix := part[0]
-		val := part[2:]
-
-		switch prefix {
-		case 's': // size
-			size, err := strconv.ParseFloat(val, 64)
-			if err != nil {
-				return fmt.Errorf("invalid text size: %w", err)
-			}
-			config.TextSize = size
-		case 'c': // color
-			col, err := generator.ParseColor(val)
-			if err != nil {
-				return fmt.Errorf("invalid text color: %w", err)
-			}
-			config.TextColor = &col
-		case 'a': // angle
-			angle, err := strconv.ParseFloat(val, 64)
-			if err != nil {
-				return fmt.Errorf("invalid text angle: %w", err)
-			}
-			config.TextAngle = angle
-		default:
-			return fmt.Errorf("unknown text parameter: %c", prefix)
-		}
-	}
-
-	return nil
-}
-
-// splitRespectingQuotes splits a string by commas while respecting quoted sections
-func splitRespectingQuotes(s string) []string {
-	var parts []string
-	var current strings.Builder
-	inQuotes := false
-
-	for i := 0; i < len(s); i++ {
-		char := s[i]
-
-		if char == '"' {
-			inQuotes = !inQuotes
-			current.WriteByte(char)
-		} else if char == ',' && !inQuotes {
-			// Split here
-			if current.Len() > 0 {
-				parts = append(parts, current.String())
-				current.Reset()
-			}
-		} else {
-			current.WriteByte(char)
-		}
+	if err := s.Shutdown(ctx); err != nil {
+		s.handler.logger.Error("Failed to shut down server gracefully", "error", err)
 	}
-
-	// Add the last part
-	if current.Len() > 0 {
-		parts = append(parts, current.String())
-	}
-
-	return parts
 }
 
-// parseBorderConfig parses border configuration
-// Format: b:width,color
-func parseBorderConfig(config *generator.ImageConfig, value string) error {
-	parts := strings.Split(value, ",")
-	if len(parts) == 0 {
-		return fmt.Errorf("empty border config")
-	}
-
-	// First part is width
-	width, err := strconv.Atoi(parts[0])
-	if err != nil {
-		return fmt.Errorf("invalid border width: %w", err)
-	}
-	config.BorderWidth = width
-
-	// Second part (if present) is color
-	if len(parts) > 1 {
-		col, err := generator.ParseColor(parts[1])
-		if err != nil {
-			return fmt.Errorf("invalid border color: %w", err)
-		}
-		config.BorderColor = col
-	}
-
-	return nil
+// Wait blocks until all listeners have stopped and the shutdown has finished, i.e. all
+// in-flight requests and renders are done or the shutdown timed out. It returns the error
+// of a listener that failed, or nil after a regular shutdown.
+func (s *Server) Wait() error {
+	s.mu.Lock()
+	done, stopped := s.done, s.stopped
+	s.mu.Unlock()
+	if done == nil {
+		return errors.New("server not started")
+	}
+
+	// The listeners stop as soon as a shutdown starts, the shutdown itself drains the
+	// requests and renders
+	<-done
+	<-stopped
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.serveErr
 }
 
-// parseSize parses a size string in format "WxH"
-func parseSize(sizeStr string) (width, height int, err error) {
-	parts := strings.Split(sizeStr, "x")
-	if len(parts) != 2 {
-		return 0, 0, fmt.Errorf("size must be in format WxH")
-	}
+// Addrs returns the addresses the server listens on, e.g. to find the port of ":0"
+func (s *Server) Addrs() []net.Addr {
+	s.mu.Lock()
+	defer s.mu.Unlock()
 
-	width, err = strconv.Atoi(parts[0])
-	if err != nil {
-		return 0, 0, fmt.Errorf("invalid width: %w", err)
+	addrs := make([]net.Addr, 0, len(s.listeners))
+	for _, ln := range s.listeners {
+		addrs = append(addrs, ln.Addr())
 	}
-
-	height, err = strconv.Atoi(parts[1])
-	if err != nil {
-		return 0, 0, fmt.Errorf("invalid height: %w", err)
-	}
-
-	if width <= 0 || height <= 0 {
-		return 0, 0, fmt.Errorf("width and height must be positive")
-	}
-
-	return width, height, nil
+	return addrs
 }
@@ -0,0 +1,182 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"image/png"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/bylexus/imagen/pkg/spec"
+)
+
+// testOptions returns the default options with a discarding logger
+func testOptions() Options {
+	options := DefaultOptions()
+	options.Logger = slog.New(slog.NewTextHandler(io.Discard, nil))
+	return options
+}
+
+// get sends a GET request with the given headers to the handler, and returns the recorded response
+func get(handler http.Handler, target string, header http.Header) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodGet, target, nil)
+	for key, values := range header {
+		req.Header[key] = values
+	}
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	return rec
+}
+
+// startTestServer starts a server on a random local port, and returns it with the
+// function that cancels its start context
+func startTestServer(t *testing.T, options Options) (*Server, context.CancelFunc) {
+	t.Helper()
+	ctx, cancel := context.WithCancel(context.Background())
+	srv := NewServer([]string{"127.0.0.1:0"}, options)
+	if err := srv.Start(ctx); err != nil {
+		cancel()
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		cancel()
+		srv.Wait()
+	})
+	return srv, cancel
+}
+
+func TestWaitReturnsAfterRendersDrained(t *testing.T) {
+	srv, cancel := startTestServer(t, testOptions())
+
+	// A render that is still running when the shutdown starts
+	srv.handler.renders.Add(1)
+	waited := make(chan error, 1)
+	go func() {
+		waited <- srv.Wait()
+	}()
+	cancel()
+
+	select {
+	case <-waited:
+		t.Fatal("Wait returned while a render was still running")
+	case <-time.After(200 * time.Millisecond):
+	}
+
+	srv.handler.renders.Done()
+	select {
+	case err := <-waited:
+		if err != nil {
+			t.Errorf("Wait returned %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Wait did not return after the render finished")
+	}
+}
+
+func TestWaitReturnsAfterShutdownTimeout(t *testing.T) {
+	options := testOptions()
+	options.ShutdownTimeout = 100 * time.Millisecond
+	srv, cancel := startTestServer(t, options)
+
+	// A render that never finishes must not block the process forever
+	srv.handler.renders.Add(1)
+	defer srv.handler.renders.Done()
+	cancel()
+
+	waited := make(chan error, 1)
+	go func() {
+		waited <- srv.Wait()
+	}()
+	select {
+	case <-waited:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Wait did not return after the shutdown timeout")
+	}
+}
+
+func TestShutdownDrainsInFlightRequests(t *testing.T) {
+	// Requests are held in the limit check until released
+	entered := make(chan struct{})
+	release := make(chan struct{})
+	options := testOptions()
+	options.CheckLimits = func(*spec.Spec) error {
+		close(entered)
+		<-release
+		return nil
+	}
+	srv, cancel := startTestServer(t, options)
+	addr := srv.listeners[0].Addr().String()
+
+	type response struct {
+		status int
+		body   []byte
+		err    error
+	}
+	responses := make(chan response, 1)
+	go func() {
+		resp, err := http.Get("http://" + addr + "/300x200/g:red,blue")
+		if err != nil {
+			responses <- response{err: err}
+			return
+		}
+		defer resp.Body.Close()
+		body, err := io.ReadAll(resp.Body)
+		responses <- response{status: resp.StatusCode, body: body, err: err}
+	}()
+	<-entered
+
+	waited := make(chan error, 1)
+	go func() {
+		waited <- srv.Wait()
+	}()
+	cancel()
+
+	// New connections are refused and the server is not ready anymore, while the
+	// request is still running
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		conn, err := net.DialTimeout("tcp", addr, time.Second)
+		if err != nil {
+			break
+		}
+		conn.Close()
+		if time.Now().After(deadline) {
+			t.Fatal("the server still accepts connections during the shutdown")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if rec := get(srv.handler, "/_imagen/ready", nil); rec.Code != http.StatusServiceUnavailable {
+		t.Errorf("readiness status = %d during the shutdown, want %d", rec.Code, http.StatusServiceUnavailable)
+	}
+	select {
+	case <-waited:
+		t.Fatal("Wait returned while a request was still running")
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	close(release)
+	resp := <-responses
+	if resp.err != nil {
+		t.Fatalf("in-flight request failed: %v", resp.err)
+	}
+	if resp.status != http.StatusOK {
+		t.Errorf("in-flight request status = %d, want %d", resp.status, http.StatusOK)
+	}
+	if _, err := png.Decode(bytes.NewReader(resp.body)); err != nil {
+		t.Errorf("in-flight request returned an invalid image: %v", err)
+	}
+
+	select {
+	case err := <-waited:
+		if err != nil {
+			t.Errorf("Wait returned %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Wait did not return after the request finished")
+	}
+}
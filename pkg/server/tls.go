@@ -0,0 +1,125 @@
+package server
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"math/big"
+	"net"
+	"strings"
+	"time"
+)
+
+// listenAddress is a parsed listen address
+type listenAddress struct {
+	addr   string
+	useTLS bool
+}
+
+// parseListenAddress parses a listen address with an optional scheme: "https://:3443" uses TLS,
+// "http://:3000" does not. Without a scheme, TLS is used if a certificate is configured.
+// Other schemes and addresses without a port are rejected.
+func parseListenAddress(addr string, tlsDefault bool) (listenAddress, error) {
+	parsed := listenAddress{addr: addr, useTLS: tlsDefault}
+	if scheme, rest, ok := strings.Cut(addr, "://"); ok {
+		switch strings.ToLower(scheme) {
+		case "https":
+			parsed = listenAddress{addr: rest, useTLS: true}
+		case "http":
+			parsed = listenAddress{addr: rest}
+		default:
+			return listenAddress{}, fmt.Errorf("invalid listen address %s: unsupported scheme %s, use http or https", addr, scheme)
+		}
+	}
+	if _, _, err := net.SplitHostPort(parsed.addr); err != nil {
+		return listenAddress{}, fmt.Errorf("invalid listen address %s: %w", addr, err)
+	}
+	return parsed, nil
+}
+
+// hasTLSCertificate returns true if a certificate file or a self-signed certificate is configured
+func (o Options) hasTLSCertificate() bool {
+	return o.TLSCertFile != "" || o.TLSSelfSigned
+}
+
+// tlsConfig loads or generates the server certificate and returns the TLS configuration,
+// with HTTP/2 enabled
+func (o Options) tlsConfig() (*tls.Config, error) {
+	var cert tls.Certificate
+	switch {
+	case o.TLSCertFile != "" && o.TLSSelfSigned:
+		return nil, errors.New("a certificate file and a self-signed certificate cannot be used together")
+	case o.TLSCertFile != "" || o.TLSKeyFile != "":
+		if o.TLSCertFile == "" || o.TLSKeyFile == "" {
+			return nil, errors.New("TLS needs both a certificate and a key file")
+		}
+		var err error
+		cert, err = tls.LoadX509KeyPair(o.TLSCertFile, o.TLSKeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load TLS certificate: %w", err)
+		}
+	case o.TLSSelfSigned:
+		var err error
+		cert, err = selfSignedCertificate()
+		if err != nil {
+			return nil, fmt.Errorf("failed to create self-signed certificate: %w", err)
+		}
+	default:
+		return nil, errors.New("TLS needs a certificate and key file, or a self-signed certificate")
+	}
+
+	return &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		MinVersion:   tls.VersionTLS12,
+		NextProtos:   []string{"h2", "http/1.1"},
+	}, nil
+}
+
+// selfSignedCertificate creates a self-signed certificate for localhost, valid for one year
+func selfSignedCertificate() (tls.Certificate, error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+
+	serial, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+
+	now := time.Now()
+	template := &x509.Certificate{
+		SerialNumber:          serial,
+		Subject:               pkix.Name{Organization: []string{"imagen"}, CommonName: "localhost"},
+		NotBefore:             now.Add(-time.Hour),
+		NotAfter:              now.AddDate(1, 0, 0),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+		DNSNames:              []string{"localhost"},
+		IPAddresses:           []net.IP{net.IPv4(127, 0, 0, 1), net.IPv6loopback},
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key}, nil
+}
+
+// certificateFingerprint returns the SHA-256 fingerprint of the leaf certificate
+func certificateFingerprint(cert tls.Certificate) string {
+	if len(cert.Certificate) == 0 {
+		return ""
+	}
+	sum := sha256.Sum256(cert.Certificate[0])
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,207 @@
+package server
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestParseListenAddress(t *testing.T) {
+	tests := []struct {
+		addr       string
+		tlsDefault bool
+		want       listenAddress
+		wantError  string
+	}{
+		{":3000", false, listenAddress{addr: ":3000"}, ""},
+		{":3000", true, listenAddress{addr: ":3000", useTLS: true}, ""},
+		{"http://:3000", true, listenAddress{addr: ":3000"}, ""},
+		{"https://:3443", false, listenAddress{addr: ":3443", useTLS: true}, ""},
+		{"HTTPS://127.0.0.1:3443", false, listenAddress{addr: "127.0.0.1:3443", useTLS: true}, ""},
+		{"http://192.168.1.20:5555", false, listenAddress{addr: "192.168.1.20:5555"}, ""},
+		{"https://[::1]:4567", false, listenAddress{addr: "[::1]:4567", useTLS: true}, ""},
+		{"localhost:0", false, listenAddress{addr: "localhost:0"}, ""},
+		{"3000", false, listenAddress{}, "missing port in address"},
+		{"https://", false, listenAddress{}, "missing port in address"},
+		{"http://localhost", false, listenAddress{}, "missing port in address"},
+		{"::1:3000", false, listenAddress{}, "too many colons"},
+		{"ftp://:21", false, listenAddress{}, "unsupported scheme ftp"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.addr, func(t *testing.T) {
+			got, err := parseListenAddress(tt.addr, tt.tlsDefault)
+			if tt.wantError != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantError) {
+					t.Errorf("parseListenAddress() = %+v, %v, want an error containing %q", got, err, tt.wantError)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("parseListenAddress() returned %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("parseListenAddress() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestStartRejectsInvalidAddresses(t *testing.T) {
+	tests := []struct {
+		addresses []string
+		wantError string
+	}{
+		{[]string{"127.0.0.1:0", "ftp://:21"}, "unsupported scheme ftp"},
+		{[]string{"127.0.0.1"}, "missing port in address"},
+		{[]string{"https://127.0.0.1:0"}, "TLS needs a certificate"},
+		{[]string{"127.0.0.1:0", "127.0.0.1:unknown-port"}, "failed to start server on 127.0.0.1:unknown-port"},
+	}
+	for _, tt := range tests {
+		t.Run(strings.Join(tt.addresses, ","), func(t *testing.T) {
+			srv := NewServer(tt.addresses, testOptions())
+			err := srv.Start(context.Background())
+			if err == nil {
+				srv.Shutdown(context.Background())
+				t.Fatalf("Start() succeeded, want an error containing %q", tt.wantError)
+			}
+			if !strings.Contains(err.Error(), tt.wantError) {
+				t.Errorf("Start() = %v, want an error containing %q", err, tt.wantError)
+			}
+			if len(srv.listeners) != 0 {
+				t.Errorf("%d listeners are open after the failed start", len(srv.listeners))
+			}
+		})
+	}
+}
+
+func TestTLSConfigErrors(t *testing.T) {
+	dir := t.TempDir()
+	cert := filepath.Join(dir, "cert.pem")
+	if err := os.WriteFile(cert, []byte("no certificate"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name      string
+		options   Options
+		wantError string
+	}{
+		{"nothing configured", Options{}, "TLS needs a certificate and key file"},
+		{"certificate without key", Options{TLSCertFile: cert}, "TLS needs both a certificate and a key file"},
+		{"key without certificate", Options{TLSKeyFile: cert}, "TLS needs both a certificate and a key file"},
+		{"file and self-signed", Options{TLSCertFile: cert, TLSKeyFile: cert, TLSSelfSigned: true}, "cannot be used together"},
+		{"invalid files", Options{TLSCertFile: cert, TLSKeyFile: cert}, "failed to load TLS certificate"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := tt.options.tlsConfig(); err == nil || !strings.Contains(err.Error(), tt.wantError) {
+				t.Errorf("tlsConfig() = %v, want an error containing %q", err, tt.wantError)
+			}
+		})
+	}
+}
+
+func TestSelfSignedCertificate(t *testing.T) {
+	config, err := Options{TLSSelfSigned: true}.tlsConfig()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if config.MinVersion != tls.VersionTLS12 {
+		t.Errorf("minimum TLS version = %x, want TLS 1.2", config.MinVersion)
+	}
+	if len(config.NextProtos) == 0 || config.NextProtos[0] != "h2" {
+		t.Errorf("NextProtos = %q, want h2 first", config.NextProtos)
+	}
+
+	leaf, err := x509.ParseCertificate(config.Certificates[0].Certificate[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	roots := x509.NewCertPool()
+	roots.AddCert(leaf)
+	for _, name := range []string{"localhost", "127.0.0.1", "::1"} {
+		if _, err := leaf.Verify(x509.VerifyOptions{Roots: roots, DNSName: name}); err != nil {
+			t.Errorf("certificate is not valid for %s: %v", name, err)
+		}
+	}
+	if fingerprint := certificateFingerprint(config.Certificates[0]); len(fingerprint) != 64 {
+		t.Errorf("fingerprint = %q, want 64 hex digits", fingerprint)
+	}
+}
+
+func TestSelfSignedHandshakeNegotiatesHTTP2(t *testing.T) {
+	config, err := Options{TLSSelfSigned: true}.tlsConfig()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ts := httptest.NewUnstartedServer(Handler(testOptions()))
+	ts.TLS = config
+	ts.EnableHTTP2 = true
+	ts.StartTLS()
+	defer ts.Close()
+
+	// The test client trusts the self-signed certificate of the server
+	resp, err := ts.Client().Get(ts.URL + "/40x30")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, resp.Body)
+
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("status = %d, want %d", resp.StatusCode, http.StatusOK)
+	}
+	if resp.ProtoMajor != 2 || resp.TLS.NegotiatedProtocol != "h2" {
+		t.Errorf("protocol = %s, negotiated %q, want HTTP/2 over h2", resp.Proto, resp.TLS.NegotiatedProtocol)
+	}
+}
+
+func TestServerServesHTTPAndHTTPS(t *testing.T) {
+	options := testOptions()
+	options.TLSSelfSigned = true
+	ctx, cancel := context.WithCancel(context.Background())
+	srv := NewServer([]string{"http://127.0.0.1:0", "https://127.0.0.1:0"}, options)
+	if err := srv.Start(ctx); err != nil {
+		cancel()
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		cancel()
+		srv.Wait()
+	})
+	plainAddr, tlsAddr := srv.listeners[0].Addr().String(), srv.listeners[1].Addr().String()
+
+	// Plain HTTP/1.1 on the http:// address
+	resp, err := http.Get("http://" + plainAddr + "/40x30")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK || resp.TLS != nil {
+		t.Errorf("http:// answered %d with TLS %v, want 200 without TLS", resp.StatusCode, resp.TLS != nil)
+	}
+
+	// HTTP/2 is offered through ALPN on the https:// address
+	for _, protos := range [][]string{{"h2", "http/1.1"}, {"http/1.1"}} {
+		conn, err := tls.Dial("tcp", tlsAddr, &tls.Config{InsecureSkipVerify: true, NextProtos: protos})
+		if err != nil {
+			t.Fatal(err)
+		}
+		state := conn.ConnectionState()
+		conn.Close()
+		if state.NegotiatedProtocol != protos[0] {
+			t.Errorf("client offering %q negotiated %q, want %q", protos, state.NegotiatedProtocol, protos[0])
+		}
+		if cn := state.PeerCertificates[0].Subject.CommonName; cn != "localhost" {
+			t.Errorf("certificate common name = %q, want localhost", cn)
+		}
+	}
+}
@@ -0,0 +1,638 @@
+package spec
+
+import (
+	"fmt"
+	"math"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/bylexus/imagen/pkg/generator"
+)
+
+// backgroundPrefixes maps the single-char URL prefixes to the background color modes
+var backgroundPrefixes = map[byte]generator.ColorMode{
+	'c': generator.ColorModeSolid,
+	'g': generator.ColorModeGradient,
+	'r': generator.ColorModeRadial,
+	'k': generator.ColorModeConic,
+	't': generator.ColorModeTiled,
+	'n': generator.ColorModeNoise,
+}
+
+// BackgroundPrefix returns the single-char URL prefix of the given color mode
+func BackgroundPrefix(mode generator.ColorMode) byte {
+	for prefix, m := range backgroundPrefixes {
+		if m == mode {
+			return prefix
+		}
+	}
+	return 0
+}
+
+// ParsePath parses a URL path into a spec
+// URL format: /[size]/[c|g|r|k|t|n]:[color-config]/t:[text]/f:[format]/b:[border]/a:[animation]/s:[seed]
+// Path segments may be URL-escaped, so texts can contain slashes (%2F).
+func ParsePath(path string) (*Spec, error) {
+	return ParseURL(path, "")
+}
+
+// ParseURL parses a URL path and its raw query string into a spec. See ApplyQuery
+// for the query parameters and how they are combined with the path.
+func ParseURL(path, rawQuery string) (*Spec, error) {
+	return defaultParser.ParseURL(path, rawQuery)
+}
+
+// parseSizeSegment parses the size path segment, e.g. "400x300" or "400x300.png"
+func (s *Spec) parseSizeSegment(part string) error {
+	if idx := strings.LastIndex(part, "."); idx != -1 {
+		format := strings.ToLower(part[idx+1:])
+		if !isSupportedFormat(format) {
+			return fmt.Errorf("unsupported format: %s", format)
+		}
+		if err := setField(s, "format", &s.Format, format); err != nil {
+			return err
+		}
+		part = part[:idx]
+	}
+
+	width, height, err := ParseSize(part)
+	if err != nil {
+		return fmt.Errorf("invalid size: %w", err)
+	}
+	if err := setField(s, "width", &s.Width, width); err != nil {
+		return err
+	}
+	return setField(s, "height", &s.Height, height)
+}
+
+// parseFloat parses a decimal number. NaN and infinite values are rejected, as they
+// cannot be rendered.
+func parseFloat(value string) (float64, error) {
+	f, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		return 0, err
+	}
+	if math.IsNaN(f) || math.IsInf(f, 0) {
+		return 0, fmt.Errorf("%s is not a finite number", value)
+	}
+	return f, nil
+}
+
+// setField sets a spec field and marks it as explicitly set. Setting an explicitly
+// set field to a different value is an error, no matter where the values come from.
+func setField[T comparable](s *Spec, name string, field *T, value T) error {
+	if s.explicit[name] && *field != value {
+		return fmt.Errorf("conflicting values for %s: %v and %v", name, *field, value)
+	}
+	if s.explicit == nil {
+		s.explicit = make(map[string]bool)
+	}
+	*field = value
+	s.explicit[name] = true
+	return nil
+}
+
+// ApplySegment parses a single prefixed URL path segment (e.g. "g:red,blue:45") into the spec
+func (s *Spec) ApplySegment(part string) error {
+	if len(part) < 2 || part[1] != ':' {
+		return fmt.Errorf("invalid parameter format: %s", part)
+	}
+
+	prefix := part[0]
+	value := part[2:]
+
+	// 't' is either text (quoted) or a tiled background
+	if prefix == 't' && strings.HasPrefix(value, "\"") {
+		if err := s.parseText(value); err != nil {
+			return fmt.Errorf("invalid text config: %w", err)
+		}
+		return nil
+	}
+
+	if mode, ok := backgroundPrefixes[prefix]; ok {
+		bg, err := ParseBackground(mode, value)
+		if err != nil {
+			return fmt.Errorf("invalid %s background: %w", mode, err)
+		}
+		s.Backgrounds = append(s.Backgrounds, bg)
+		return nil
+	}
+
+	switch prefix {
+	case 'f': // format
+		if err := s.parseFormat(value); err != nil {
+			return fmt.Errorf("invalid format config: %w", err)
+		}
+	case 'b': // border
+		if err := s.parseBorder(value); err != nil {
+			return fmt.Errorf("invalid border config: %w", err)
+		}
+	case 'a': // animation
+		if err := s.parseAnimation(value); err != nil {
+			return fmt.Errorf("invalid animation config: %w", err)
+		}
+	case 's': // seed
+		if err := s.parseSeed(value); err != nil {
+			return fmt.Errorf("invalid seed: %w", err)
+		}
+	default:
+		return fmt.Errorf("unknown parameter prefix: %c", prefix)
+	}
+	return nil
+}
+
+// ParseBackground parses a background color definition of the given mode
+// Format examples:
+//   - solid: "blue" or "blue:t:white"
+//   - gradient: "red,blue" or "red,blue:45" or "red,blue:45:t:white"
+//   - radial: "red,blue" or "red,blue:80" or "red,blue:80:25,50" or "red,blue:25,50:t:white"
+//   - conic: "red,blue" or "red,blue:90" or "red,blue:90:25,50" or "red,blue:25,50:t:white"
+//   - tiles: "red,blue" or "red,blue:10" or "red,blue:10:t:white"
+//   - noise: "red,blue,green" or "red,blue,green:10" or "red,blue,green:10:t:white"
+func ParseBackground(mode generator.ColorMode, value string) (Background, error) {
+	bg := NewBackground(mode)
+
+	// Check for text color override at the end (:t:color)
+	if idx := strings.LastIndex(value, ":t:"); idx != -1 {
+		textColor := normalizeColor(value[idx+3:])
+		value = value[:idx]
+
+		if _, err := generator.ParseColor(textColor); err != nil {
+			return bg, fmt.Errorf("invalid text color %s: %w", textColor, err)
+		}
+		bg.TextColor = textColor
+	}
+
+	// Split colors from the optional mode parameters
+	sections := strings.Split(value, ":")
+	colors, err := parseColorList(sections[0])
+	if err != nil {
+		return bg, err
+	}
+	bg.Colors = colors
+	options := sections[1:]
+
+	switch mode {
+	case generator.ColorModeSolid:
+		// Format: color
+		if len(colors) != 1 {
+			return bg, fmt.Errorf("solid background requires exactly 1 color")
+		}
+		if len(options) > 0 {
+			return bg, fmt.Errorf("invalid solid background format")
+		}
+
+	case generator.ColorModeGradient:
+		// Format: color1,color2[,color3...][:angle]
+		if len(colors) < 2 {
+			return bg, fmt.Errorf("gradient requires at least 2 colors")
+		}
+		if len(options) > 1 {
+			return bg, fmt.Errorf("invalid gradient format")
+		}
+		if len(options) == 1 {
+			angle, err := parseFloat(options[0])
+			if err != nil {
+				return bg, fmt.Errorf("invalid gradient angle %s: %w", options[0], err)
+			}
+			bg.Angle = angle
+		}
+
+	case generator.ColorModeRadial, generator.ColorModeConic:
+		// Format: color1,color2[,color3...][:radius|angle][:cx,cy], options in any order
+		if len(colors) < 2 {
+			return bg, fmt.Errorf("%s gradient requires at least 2 colors", mode)
+		}
+		if len(options) > 2 {
+			return bg, fmt.Errorf("invalid %s format", mode)
+		}
+		for _, opt := range options {
+			if strings.Contains(opt, ",") {
+				center := strings.Split(opt, ",")
+				if len(center) != 2 {
+					return bg, fmt.Errorf("invalid gradient center %s", opt)
+				}
+				cx, err1 := parseFloat(strings.TrimSpace(center[0]))
+				cy, err2 := parseFloat(strings.TrimSpace(center[1]))
+				if err1 != nil || err2 != nil {
+					return bg, fmt.Errorf("invalid gradient center %s", opt)
+				}
+				bg.CenterX = cx
+				bg.CenterY = cy
+				continue
+			}
+
+			v, err := parseFloat(opt)
+			if err != nil {
+				return bg, fmt.Errorf("invalid %s parameter %s: %w", mode, opt, err)
+			}
+			if mode == generator.ColorModeRadial {
+				if v <= 0 {
+					return bg, fmt.Errorf("radius must be positive")
+				}
+				bg.Radius = v
+			} else {
+				bg.Angle = v
+			}
+		}
+
+	case generator.ColorModeTiled, generator.ColorModeNoise:
+		// Format: color1,color2[,color3...][:tilesize]
+		if len(colors) < 2 {
+			return bg, fmt.Errorf("%s requires at least 2 colors", mode)
+		}
+		if len(options) > 1 {
+			return bg, fmt.Errorf("invalid %s format", mode)
+		}
+		if len(options) == 1 {
+			tileSize, err := strconv.Atoi(options[0])
+			if err != nil {
+				return bg, fmt.Errorf("invalid tile size %s: %w", options[0], err)
+			}
+			if tileSize <= 0 {
+				return bg, fmt.Errorf("tile size must be positive")
+			}
+			bg.TileSize = tileSize
+		}
+
+	default:
+		return bg, fmt.Errorf("unsupported color mode: %s", mode)
+	}
+
+	return bg, nil
+}
+
+// parseColorList parses and validates a comma-separated list of colors
+func parseColorList(value string) ([]string, error) {
+	var colors []string
+	for _, colorStr := range strings.Split(value, ",") {
+		colorStr = normalizeColor(colorStr)
+		if _, err := generator.ParseColor(colorStr); err != nil {
+			return nil, fmt.Errorf("invalid color %s: %w", colorStr, err)
+		}
+		colors = append(colors, colorStr)
+	}
+	return colors, nil
+}
+
+// normalizeColor returns the canonical form of a color string (lower case, no '#')
+func normalizeColor(colorStr string) string {
+	return strings.TrimPrefix(strings.ToLower(strings.TrimSpace(colorStr)), "#")
+}
+
+// ParseSize parses a size string in format "WxH"
+func ParseSize(sizeStr string) (width, height int, err error) {
+	parts := strings.Split(sizeStr, "x")
+	if len(parts) != 2 {
+		return 0, 0, fmt.Errorf("size must be in format WxH")
+	}
+
+	width, err = strconv.Atoi(parts[0])
+	if err != nil {
+		return 0, 0, fmt.Errorf("invalid width: %w", err)
+	}
+
+	height, err = strconv.Atoi(parts[1])
+	if err != nil {
+		return 0, 0, fmt.Errorf("invalid height: %w", err)
+	}
+
+	if width <= 0 || height <= 0 {
+		return 0, 0, fmt.Errorf("width and height must be positive")
+	}
+
+	return width, height, nil
+}
+
+// ParseBorder parses a border definition. The color is optional (empty means black).
+// Format: width[,color]
+func ParseBorder(value string) (width int, col string, err error) {
+	parts := strings.Split(value, ",")
+	if len(parts) > 2 {
+		return 0, "", fmt.Errorf("border must be in format width[,color]")
+	}
+
+	width, err = strconv.Atoi(strings.TrimSpace(parts[0]))
+	if err != nil {
+		return 0, "", fmt.Errorf("invalid border width: %w", err)
+	}
+	if width < 0 {
+		return 0, "", fmt.Errorf("border width must not be negative")
+	}
+
+	if len(parts) == 2 {
+		col = normalizeColor(parts[1])
+		if _, err := generator.ParseColor(col); err != nil {
+			return 0, "", fmt.Errorf("invalid border color: %w", err)
+		}
+	}
+
+	return width, col, nil
+}
+
+// parseBorder parses a border definition into the spec
+func (s *Spec) parseBorder(value string) error {
+	width, col, err := ParseBorder(value)
+	if err != nil {
+		return err
+	}
+	if err := setField(s, "borderWidth", &s.BorderWidth, width); err != nil {
+		return err
+	}
+	if col != "" {
+		return setField(s, "borderColor", &s.BorderColor, col)
+	}
+	return nil
+}
+
+// ParseColorValue parses and validates a single color, returning its canonical form
+func ParseColorValue(value string) (string, error) {
+	col := normalizeColor(value)
+	if _, err := generator.ParseColor(col); err != nil {
+		return "", err
+	}
+	return col, nil
+}
+
+// parseText parses text configuration
+// Format: "text"[,s:size][,c:color][,a:angle][,f:font][,w:wrap][,l:lineheight]
+// Within the quotes, \" stands for a quote and \\ for a backslash, see unquoteText.
+func (s *Spec) parseText(value string) error {
+	// Split by comma while respecting quotes
+	parts := splitRespectingQuotes(value)
+	if len(parts) == 0 {
+		return fmt.Errorf("empty text config")
+	}
+
+	// First part is the quoted text
+	text, err := unquoteText(parts[0])
+	if err != nil {
+		return err
+	}
+	if err := setField(s, "text", &s.Text, text); err != nil {
+		return err
+	}
+
+	// Parse remaining parts
+	for _, part := range parts[1:] {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+
+		if len(part) < 2 || part[1] != ':' {
+			return fmt.Errorf("invalid text parameter: %s", part)
+		}
+
+		prefix := part[0]
+		val := part[2:]
+
+		switch prefix {
+		case 's': // size
+			size, err := parseFloat(val)
+			if err != nil {
+				return fmt.Errorf("invalid text size: %w", err)
+			}
+			if size <= 0 {
+				return fmt.Errorf("text size must be positive")
+			}
+			if err := setField(s, "textSize", &s.TextSize, size); err != nil {
+				return err
+			}
+		case 'c': // color
+			col, err := ParseColorValue(val)
+			if err != nil {
+				return fmt.Errorf("invalid text color: %w", err)
+			}
+			if err := setField(s, "textColor", &s.TextColor, col); err != nil {
+				return err
+			}
+		case 'a': // angle
+			angle, err := parseFloat(val)
+			if err != nil {
+				return fmt.Errorf("invalid text angle: %w", err)
+			}
+			if err := setField(s, "textAngle", &s.TextAngle, angle); err != nil {
+				return err
+			}
+		case 'w': // wrap width in percent
+			wrap, err := parseFloat(val)
+			if err != nil {
+				return fmt.Errorf("invalid text wrap width: %w", err)
+			}
+			if err := setField(s, "textWrap", &s.TextWrap, wrap); err != nil {
+				return err
+			}
+		case 'l': // line height
+			lineHeight, err := parseFloat(val)
+			if err != nil {
+				return fmt.Errorf("invalid line height: %w", err)
+			}
+			if err := setField(s, "lineHeight", &s.LineHeight, lineHeight); err != nil {
+				return err
+			}
+		case 'f': // font
+			if err := s.parseFont(val); err != nil {
+				return err
+			}
+		default:
+			return fmt.Errorf("unknown text parameter: %c", prefix)
+		}
+	}
+
+	return nil
+}
+
+// parseFont sets the font of the text. URLs may only select installed fonts by name,
+// not font files by path.
+func (s *Spec) parseFont(name string) error {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return fmt.Errorf("empty font name")
+	}
+	if generator.IsFontPath(name) {
+		return fmt.Errorf("invalid font %q: fonts are selected by name, not by path", name)
+	}
+	return setField(s, "font", &s.Font, name)
+}
+
+// parseFormat parses output format configuration
+// Format: format[,q:quality][,lossless]
+func (s *Spec) parseFormat(value string) error {
+	parts := strings.Split(value, ",")
+	format := strings.ToLower(strings.TrimSpace(parts[0]))
+	if !isSupportedFormat(format) {
+		return fmt.Errorf("unsupported format: %s", format)
+	}
+	if err := setField(s, "format", &s.Format, format); err != nil {
+		return err
+	}
+
+	for _, part := range parts[1:] {
+		part = strings.TrimSpace(part)
+		switch {
+		case part == "":
+			continue
+		case part == "lossless":
+			if err := setField(s, "lossless", &s.Lossless, true); err != nil {
+				return err
+			}
+		case strings.HasPrefix(part, "q:"):
+			quality, err := strconv.Atoi(part[2:])
+			if err != nil || quality < 1 || quality > 100 {
+				return fmt.Errorf("invalid quality: %s", part[2:])
+			}
+			if err := setField(s, "quality", &s.Quality, quality); err != nil {
+				return err
+			}
+		default:
+			return fmt.Errorf("unknown format parameter: %s", part)
+		}
+	}
+
+	return nil
+}
+
+// parseAnimation parses animation configuration
+// Format: frames[,d:delay][,r:angle-step][,shift]
+func (s *Spec) parseAnimation(value string) error {
+	parts := strings.Split(value, ",")
+
+	frames, err := strconv.Atoi(strings.TrimSpace(parts[0]))
+	if err != nil || frames < 1 {
+		return fmt.Errorf("invalid frame count: %s", parts[0])
+	}
+	if err := setField(s, "frames", &s.Frames, frames); err != nil {
+		return err
+	}
+
+	for _, part := range parts[1:] {
+		part = strings.TrimSpace(part)
+		switch {
+		case part == "":
+			continue
+		case part == "shift":
+			if err := setField(s, "frameShift", &s.FrameColorShift, true); err != nil {
+				return err
+			}
+		case strings.HasPrefix(part, "d:"):
+			delay, err := strconv.Atoi(part[2:])
+			if err != nil || delay < 0 || delay > 65535 {
+				return fmt.Errorf("invalid frame delay: %s", part[2:])
+			}
+			if err := setField(s, "delay", &s.FrameDelay, delay); err != nil {
+				return err
+			}
+		case strings.HasPrefix(part, "r:"):
+			step, err := parseFloat(part[2:])
+			if err != nil {
+				return fmt.Errorf("invalid angle step: %w", err)
+			}
+			if err := setField(s, "frameAngle", &s.FrameAngleStep, step); err != nil {
+				return err
+			}
+		default:
+			return fmt.Errorf("unknown animation parameter: %s", part)
+		}
+	}
+
+	return nil
+}
+
+// parseSeed parses the seed parameter
+// Format: [number] or "path" (derives the seed from the URL)
+func (s *Spec) parseSeed(value string) error {
+	if value == "path" {
+		if s.Seed != nil {
+			return fmt.Errorf("conflicting values for seed: %d and path", *s.Seed)
+		}
+		s.SeedFromPath = true
+		return nil
+	}
+
+	seed, err := strconv.ParseInt(value, 10, 64)
+	if err != nil {
+		return err
+	}
+	if s.SeedFromPath {
+		return fmt.Errorf("conflicting values for seed: path and %d", seed)
+	}
+	if s.Seed != nil && *s.Seed != seed {
+		return fmt.Errorf("conflicting values for seed: %d and %d", *s.Seed, seed)
+	}
+	s.Seed = &seed
+	return nil
+}
+
+// splitRespectingQuotes splits a string by commas while respecting quoted sections
+func splitRespectingQuotes(s string) []string {
+	var parts []string
+	var current strings.Builder
+	inQuotes := false
+
+	for i := 0; i < len(s); i++ {
+		char := s[i]
+
+		if inQuotes && char == '\\' && i+1 < len(s) && (s[i+1] == '"' || s[i+1] == '\\') {
+			// Escaped quote or backslash, kept for unquoteText
+			current.WriteString(s[i : i+2])
+			i++
+		} else if char == '"' {
+			inQuotes = !inQuotes
+			current.WriteByte(char)
+		} else if char == ',' && !inQuotes {
+			// Split here
+			if current.Len() > 0 {
+				parts = append(parts, current.String())
+				current.Reset()
+			}
+		} else {
+			current.WriteByte(char)
+		}
+	}
+
+	// Add the last part
+	if current.Len() > 0 {
+		parts = append(parts, current.String())
+	}
+
+	return parts
+}
+
+// unquoteText returns the text between the quotes of a quoted text, with the escape
+// sequences \" and \\ replaced by a quote and a backslash. Other backslashes are kept,
+// e.g. in the line break sequence \n. Unquoted texts are returned unchanged, and a
+// missing closing quote is accepted.
+func unquoteText(quoted string) (string, error) {
+	if !strings.HasPrefix(quoted, "\"") {
+		return quoted, nil
+	}
+
+	var b strings.Builder
+	for i := 1; i < len(quoted); i++ {
+		c := quoted[i]
+		switch {
+		case c == '\\' && i+1 < len(quoted) && (quoted[i+1] == '"' || quoted[i+1] == '\\'):
+			b.WriteByte(quoted[i+1])
+			i++
+		case c == '"':
+			if i != len(quoted)-1 {
+				return "", fmt.Errorf("unexpected characters after the quoted text: %s", quoted[i+1:])
+			}
+			return b.String(), nil
+		default:
+			b.WriteByte(c)
+		}
+	}
+	return b.String(), nil
+}
+
+// unescapeSegment decodes an URL-escaped path segment, or returns it as is if it is not validly escaped
+func unescapeSegment(segment string) string {
+	unescaped, err := url.PathUnescape(segment)
+	if err != nil {
+		return segment
+	}
+	return unescaped
+}
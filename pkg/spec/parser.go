@@ -0,0 +1,186 @@
+package spec
+
+import (
+	"fmt"
+	"hash/fnv"
+	"net/url"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+)
+
+// defaultParser parses URLs without presets, on top of the default values
+var defaultParser = NewParser(nil)
+
+// presetNamePattern matches valid preset names
+var presetNamePattern = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+
+// Parser parses URLs on top of configurable default values, and resolves named
+// presets referenced with a "p:name" path segment.
+//
+// The defaults and presets are the base of the parsed URL: all other segments and
+// query parameters override them. Backgrounds and the seed are replaced as a whole.
+type Parser struct {
+	defaults *Spec
+	presets  map[string]*Spec
+}
+
+// NewParser creates a parser with the given default values, nil means New()
+func NewParser(defaults *Spec) *Parser {
+	if defaults == nil {
+		defaults = New()
+	}
+	return &Parser{defaults: defaults.clone(), presets: make(map[string]*Spec)}
+}
+
+// AddPreset parses the URL path (with optional query string) of a preset, on top of
+// the parser's defaults, and registers it under the given name. Presets cannot
+// reference other presets.
+func (p *Parser) AddPreset(name, definition string) error {
+	if !presetNamePattern.MatchString(name) {
+		return fmt.Errorf("invalid preset name %q, only letters, digits, - and _ are allowed", name)
+	}
+	path, rawQuery, _ := strings.Cut(definition, "?")
+	s, err := p.parse(path, rawQuery, false)
+	if err != nil {
+		return fmt.Errorf("invalid preset %s: %w", name, err)
+	}
+	p.presets[name] = s
+	return nil
+}
+
+// Presets returns the names of the registered presets, sorted
+func (p *Parser) Presets() []string {
+	names := make([]string, 0, len(p.presets))
+	for name := range p.presets {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// ParseURL parses a URL path and its raw query string into a spec
+func (p *Parser) ParseURL(path, rawQuery string) (*Spec, error) {
+	return p.parse(path, rawQuery, true)
+}
+
+// parse parses a URL on top of the defaults, or the preset referenced in the path
+func (p *Parser) parse(path, rawQuery string, allowPresets bool) (*Spec, error) {
+	// Remove leading slash
+	path = strings.TrimPrefix(path, "/")
+
+	// Find the preset first, so it can be placed anywhere in the path
+	base := p.defaults
+	presetName := ""
+	var parts []pathPart
+	for _, raw := range strings.Split(path, "/") {
+		if raw == "" {
+			continue
+		}
+		part := unescapeSegment(raw)
+
+		name, ok := strings.CutPrefix(part, "p:")
+		if !ok {
+			// The first part besides the presets is the size if it has no prefix, with an
+			// optional format extension (e.g. 400x300.png)
+			parts = append(parts, pathPart{value: part, size: len(parts) == 0 && !strings.Contains(part, ":")})
+			continue
+		}
+		if !allowPresets {
+			return nil, fmt.Errorf("presets cannot be nested: %s", part)
+		}
+		if presetName != "" && name != presetName {
+			return nil, fmt.Errorf("conflicting values for preset: %s and %s", presetName, name)
+		}
+		preset, ok := p.presets[name]
+		if !ok {
+			return nil, fmt.Errorf("unknown preset: %s", name)
+		}
+		base, presetName = preset, name
+	}
+
+	// Backgrounds and seed of the base are only used if the URL does not define its own
+	s := base.clone()
+	baseBackgrounds, baseSeed, baseSeedFromPath := s.Backgrounds, s.Seed, s.SeedFromPath
+	s.Backgrounds, s.Seed, s.SeedFromPath = nil, nil, false
+
+	for _, part := range parts {
+		if part.size {
+			if err := s.parseSizeSegment(part.value); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		if isSizeSegment(part.value) {
+			return nil, fmt.Errorf("size must be the first segment: %s", part.value)
+		}
+		if err := s.ApplySegment(part.value); err != nil {
+			return nil, err
+		}
+	}
+
+	if rawQuery != "" {
+		values, err := url.ParseQuery(rawQuery)
+		if err != nil {
+			return nil, fmt.Errorf("invalid query string: %w", err)
+		}
+		if err := s.ApplyQuery(values); err != nil {
+			return nil, err
+		}
+	}
+
+	if len(s.Backgrounds) == 0 {
+		s.Backgrounds = baseBackgrounds
+	}
+	if s.Seed == nil && !s.SeedFromPath {
+		s.Seed, s.SeedFromPath = baseSeed, baseSeedFromPath
+	}
+
+	// The same URL always results in the same seed
+	if s.SeedFromPath {
+		h := fnv.New64a()
+		h.Write([]byte(path))
+		if rawQuery != "" {
+			h.Write([]byte("?" + rawQuery))
+		}
+		seed := int64(h.Sum64())
+		s.Seed = &seed
+	}
+
+	if err := s.Validate(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// isSizeSegment reports whether the path segment is a size, e.g. "400x300" or "400x300.png"
+func isSizeSegment(part string) bool {
+	if idx := strings.LastIndex(part, "."); idx != -1 {
+		part = part[:idx]
+	}
+	_, _, err := ParseSize(part)
+	return err == nil
+}
+
+// pathPart is an unescaped URL path segment
+type pathPart struct {
+	value string
+	size  bool // the size segment, without prefix
+}
+
+// clone returns a copy of the spec, without the explicitly set fields, so every value
+// of the copy can be overridden
+func (s *Spec) clone() *Spec {
+	c := *s
+	c.Backgrounds = slices.Clone(s.Backgrounds)
+	for i := range c.Backgrounds {
+		c.Backgrounds[i].Colors = slices.Clone(c.Backgrounds[i].Colors)
+	}
+	if s.Seed != nil {
+		seed := *s.Seed
+		c.Seed = &seed
+	}
+	c.explicit = nil
+	return &c
+}
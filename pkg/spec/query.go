@@ -0,0 +1,266 @@
+package spec
+
+import (
+	"fmt"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/bylexus/imagen/pkg/generator"
+)
+
+// queryBackgroundModes maps the values of the "bg" query parameter to the color modes
+var queryBackgroundModes = map[string]generator.ColorMode{
+	"solid":    generator.ColorModeSolid,
+	"gradient": generator.ColorModeGradient,
+	"radial":   generator.ColorModeRadial,
+	"conic":    generator.ColorModeConic,
+	"tiles":    generator.ColorModeTiled,
+	"tiled":    generator.ColorModeTiled,
+	"noise":    generator.ColorModeNoise,
+}
+
+// queryParams lists the supported query parameters
+var queryParams = map[string]bool{
+	"size": true, "width": true, "height": true,
+	"bg": true, "colors": true, "angle": true, "tileSize": true, "cx": true, "cy": true, "radius": true,
+	"text": true, "textSize": true, "textColor": true, "textAngle": true, "textWrap": true, "lineHeight": true, "font": true,
+	"border": true, "format": true, "quality": true, "lossless": true,
+	"frames": true, "delay": true, "frameAngle": true, "frameShift": true,
+	"seed": true,
+}
+
+// ApplyQuery applies query string parameters to the spec, e.g.
+// ?bg=gradient&colors=red,blue&angle=45&text=Hello%2FWorld&textSize=26
+//
+// The query complements the path: a setting may be given in the path or in the query.
+// If it is given in both places (or more than once), the values must be identical,
+// otherwise an error is returned. A query background conflicts with path backgrounds.
+func (s *Spec) ApplyQuery(values url.Values) error {
+	for key, vals := range values {
+		if !queryParams[key] {
+			return fmt.Errorf("unknown query parameter: %s", key)
+		}
+		if len(vals) > 1 {
+			return fmt.Errorf("query parameter %s given multiple times", key)
+		}
+	}
+
+	// Size
+	if v, ok := values["size"]; ok {
+		width, height, err := ParseSize(v[0])
+		if err != nil {
+			return fmt.Errorf("invalid size: %w", err)
+		}
+		if err := setField(s, "width", &s.Width, width); err != nil {
+			return err
+		}
+		if err := setField(s, "height", &s.Height, height); err != nil {
+			return err
+		}
+	}
+	if err := queryInt(s, values, "width", &s.Width); err != nil {
+		return err
+	}
+	if err := queryInt(s, values, "height", &s.Height); err != nil {
+		return err
+	}
+
+	// Background
+	if err := s.applyQueryBackground(values); err != nil {
+		return err
+	}
+
+	// Text
+	if v, ok := values["text"]; ok {
+		if err := setField(s, "text", &s.Text, v[0]); err != nil {
+			return err
+		}
+	}
+	if err := queryFloat(s, values, "textSize", &s.TextSize); err != nil {
+		return err
+	}
+	if v, ok := values["textColor"]; ok {
+		col, err := ParseColorValue(v[0])
+		if err != nil {
+			return fmt.Errorf("invalid text color: %w", err)
+		}
+		if err := setField(s, "textColor", &s.TextColor, col); err != nil {
+			return err
+		}
+	}
+	if err := queryFloat(s, values, "textAngle", &s.TextAngle); err != nil {
+		return err
+	}
+	if err := queryFloat(s, values, "textWrap", &s.TextWrap); err != nil {
+		return err
+	}
+	if err := queryFloat(s, values, "lineHeight", &s.LineHeight); err != nil {
+		return err
+	}
+	if v, ok := values["font"]; ok {
+		if err := s.parseFont(v[0]); err != nil {
+			return err
+		}
+	}
+
+	// Border
+	if v, ok := values["border"]; ok {
+		if err := s.parseBorder(v[0]); err != nil {
+			return fmt.Errorf("invalid border config: %w", err)
+		}
+	}
+
+	// Output format
+	if v, ok := values["format"]; ok {
+		format := strings.ToLower(v[0])
+		if !isSupportedFormat(format) {
+			return fmt.Errorf("unsupported format: %s", format)
+		}
+		if err := setField(s, "format", &s.Format, format); err != nil {
+			return err
+		}
+	}
+	if err := queryInt(s, values, "quality", &s.Quality); err != nil {
+		return err
+	}
+	if err := queryBool(s, values, "lossless", &s.Lossless); err != nil {
+		return err
+	}
+
+	// Animation
+	if err := queryInt(s, values, "frames", &s.Frames); err != nil {
+		return err
+	}
+	if err := queryInt(s, values, "delay", &s.FrameDelay); err != nil {
+		return err
+	}
+	if err := queryFloat(s, values, "frameAngle", &s.FrameAngleStep); err != nil {
+		return err
+	}
+	if err := queryBool(s, values, "frameShift", &s.FrameColorShift); err != nil {
+		return err
+	}
+
+	// Seed
+	if v, ok := values["seed"]; ok {
+		if err := s.parseSeed(v[0]); err != nil {
+			return fmt.Errorf("invalid seed: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// applyQueryBackground builds a background from the bg, colors and mode option parameters.
+// Without "bg", one color means a solid background and multiple colors a linear gradient.
+// The background cannot be combined with background segments in the path.
+func (s *Spec) applyQueryBackground(values url.Values) error {
+	colors, ok := values["colors"]
+	if !ok {
+		for _, key := range []string{"bg", "angle", "tileSize", "cx", "cy", "radius"} {
+			if _, ok := values[key]; ok {
+				return fmt.Errorf("query parameter %s requires colors", key)
+			}
+		}
+		return nil
+	}
+
+	var mode generator.ColorMode
+	if v, ok := values["bg"]; ok {
+		mode, ok = queryBackgroundModes[strings.ToLower(v[0])]
+		if !ok {
+			return fmt.Errorf("unknown background: %s", v[0])
+		}
+	} else if strings.Contains(colors[0], ",") {
+		mode = generator.ColorModeGradient
+	} else {
+		mode = generator.ColorModeSolid
+	}
+
+	// Only the options of the chosen mode are allowed
+	allowed := map[generator.ColorMode][]string{
+		generator.ColorModeGradient: {"angle"},
+		generator.ColorModeRadial:   {"radius", "cx", "cy"},
+		generator.ColorModeConic:    {"angle", "cx", "cy"},
+		generator.ColorModeTiled:    {"tileSize"},
+		generator.ColorModeNoise:    {"tileSize"},
+	}[mode]
+	for _, key := range []string{"angle", "tileSize", "cx", "cy", "radius"} {
+		if _, ok := values[key]; ok && !slices.Contains(allowed, key) {
+			return fmt.Errorf("query parameter %s is not supported for %s backgrounds", key, mode)
+		}
+	}
+
+	// Assemble the definition in the background grammar, so it is validated by the same parser
+	definition := colors[0]
+	for _, key := range []string{"angle", "radius", "tileSize"} {
+		if v, ok := values[key]; ok {
+			definition += ":" + v[0]
+		}
+	}
+	if values.Has("cx") || values.Has("cy") {
+		cx, cy := FormatFloat(DefaultCenter), FormatFloat(DefaultCenter)
+		if values.Has("cx") {
+			cx = values.Get("cx")
+		}
+		if values.Has("cy") {
+			cy = values.Get("cy")
+		}
+		definition += ":" + cx + "," + cy
+	}
+
+	bg, err := ParseBackground(mode, definition)
+	if err != nil {
+		return fmt.Errorf("invalid %s background: %w", mode, err)
+	}
+	// A background is defined either in the path or in the query, like every other setting
+	if len(s.Backgrounds) > 0 {
+		return fmt.Errorf("conflicting values for background: %s and %s", s.Backgrounds[0].Segment(), bg.Segment())
+	}
+	s.Backgrounds = []Background{bg}
+	return nil
+}
+
+// queryInt sets an int field from a query parameter, if present
+func queryInt(s *Spec, values url.Values, key string, field *int) error {
+	v, ok := values[key]
+	if !ok {
+		return nil
+	}
+	n, err := strconv.Atoi(v[0])
+	if err != nil {
+		return fmt.Errorf("invalid %s: %s", key, v[0])
+	}
+	return setField(s, key, field, n)
+}
+
+// queryFloat sets a float field from a query parameter, if present
+func queryFloat(s *Spec, values url.Values, key string, field *float64) error {
+	v, ok := values[key]
+	if !ok {
+		return nil
+	}
+	f, err := parseFloat(v[0])
+	if err != nil {
+		return fmt.Errorf("invalid %s: %s", key, v[0])
+	}
+	return setField(s, key, field, f)
+}
+
+// queryBool sets a bool field from a query parameter, if present. An empty value means true.
+func queryBool(s *Spec, values url.Values, key string, field *bool) error {
+	v, ok := values[key]
+	if !ok {
+		return nil
+	}
+	if v[0] == "" {
+		return setField(s, key, field, true)
+	}
+	b, err := strconv.ParseBool(v[0])
+	if err != nil {
+		return fmt.Errorf("invalid %s: %s", key, v[0])
+	}
+	return setField(s, key, field, b)
+}
@@ -0,0 +1,190 @@
+package spec
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/bylexus/imagen/pkg/generator"
+)
+
+// Path returns the canonical URL path of the spec. Default values are omitted,
+// and the parameters are always written in the same order.
+// The path is not URL-escaped, see EscapedPath.
+func (s *Spec) Path() string {
+	return "/" + strings.Join(s.Segments(), "/")
+}
+
+// EscapedPath returns the canonical URL path with every segment URL-escaped where needed
+func (s *Spec) EscapedPath() string {
+	segments := s.Segments()
+	for i, segment := range segments {
+		segments[i] = escapeSegment(segment)
+	}
+	return "/" + strings.Join(segments, "/")
+}
+
+// Segments returns the canonical, unescaped URL path segments of the spec
+func (s *Spec) Segments() []string {
+	segments := []string{fmt.Sprintf("%dx%d", s.Width, s.Height)}
+
+	for _, bg := range s.Backgrounds {
+		segments = append(segments, bg.Segment())
+	}
+
+	if text := s.textSegment(); text != "" {
+		segments = append(segments, text)
+	}
+
+	if s.BorderWidth > 0 {
+		border := fmt.Sprintf("b:%d", s.BorderWidth)
+		if s.BorderColor != "" {
+			border += "," + s.BorderColor
+		}
+		segments = append(segments, border)
+	}
+
+	if s.Frames > 1 {
+		anim := fmt.Sprintf("a:%d", s.Frames)
+		if s.FrameDelay != DefaultFrameDelay {
+			anim += fmt.Sprintf(",d:%d", s.FrameDelay)
+		}
+		if s.FrameAngleStep != 0 {
+			anim += ",r:" + FormatFloat(s.FrameAngleStep)
+		}
+		if s.FrameColorShift {
+			anim += ",shift"
+		}
+		segments = append(segments, anim)
+	}
+
+	if s.Format != DefaultFormat || s.Quality != DefaultQuality || s.Lossless {
+		format := "f:" + s.Format
+		if s.Quality != DefaultQuality {
+			format += fmt.Sprintf(",q:%d", s.Quality)
+		}
+		if s.Lossless {
+			format += ",lossless"
+		}
+		segments = append(segments, format)
+	}
+
+	if s.SeedFromPath {
+		segments = append(segments, "s:path")
+	} else if s.Seed != nil {
+		segments = append(segments, fmt.Sprintf("s:%d", *s.Seed))
+	}
+
+	return segments
+}
+
+// textSegment returns the text segment, or an empty string if all text settings are defaults
+func (s *Spec) textSegment() string {
+	if s.Text == DefaultText && s.TextSize == DefaultTextSize && s.TextColor == "" && s.TextAngle == 0 &&
+		s.TextWrap == 0 && s.LineHeight == DefaultLineHeight && s.Font == "" {
+		return ""
+	}
+
+	text := `t:"` + quoteText(s.Text) + `"`
+	if s.TextSize != DefaultTextSize {
+		text += ",s:" + FormatFloat(s.TextSize)
+	}
+	if s.TextColor != "" {
+		text += ",c:" + s.TextColor
+	}
+	if s.TextAngle != 0 {
+		text += ",a:" + FormatFloat(s.TextAngle)
+	}
+	if s.TextWrap != 0 {
+		text += ",w:" + FormatFloat(s.TextWrap)
+	}
+	if s.LineHeight != DefaultLineHeight {
+		text += ",l:" + FormatFloat(s.LineHeight)
+	}
+	if s.Font != "" {
+		text += ",f:" + s.Font
+	}
+	return text
+}
+
+// Segment returns the background definition as prefixed URL path segment, e.g. "g:red,blue:45"
+func (b Background) Segment() string {
+	return string(BackgroundPrefix(b.Mode)) + ":" + b.String()
+}
+
+// String returns the background definition without prefix, in the same form
+// as accepted by ParseBackground and the command line color flags
+func (b Background) String() string {
+	parts := []string{strings.Join(b.Colors, ",")}
+
+	switch b.Mode {
+	case generator.ColorModeGradient:
+		if b.Angle != 0 {
+			parts = append(parts, FormatFloat(b.Angle))
+		}
+	case generator.ColorModeRadial:
+		if b.Radius != DefaultRadius {
+			parts = append(parts, FormatFloat(b.Radius))
+		}
+	case generator.ColorModeConic:
+		if b.Angle != 0 {
+			parts = append(parts, FormatFloat(b.Angle))
+		}
+	case generator.ColorModeTiled, generator.ColorModeNoise:
+		if b.TileSize != DefaultTileSize {
+			parts = append(parts, strconv.Itoa(b.TileSize))
+		}
+	}
+
+	if (b.Mode == generator.ColorModeRadial || b.Mode == generator.ColorModeConic) &&
+		(b.CenterX != DefaultCenter || b.CenterY != DefaultCenter) {
+		parts = append(parts, FormatFloat(b.CenterX)+","+FormatFloat(b.CenterY))
+	}
+
+	if b.TextColor != "" {
+		parts = append(parts, "t", b.TextColor)
+	}
+
+	return strings.Join(parts, ":")
+}
+
+// quoteText escapes a text for the quotes of the text segment: quotes become \", and
+// backslashes that would otherwise start an escape sequence become \\, see unquoteText
+func quoteText(text string) string {
+	var b strings.Builder
+	for i := 0; i < len(text); i++ {
+		c := text[i]
+		switch {
+		case c == '"':
+			b.WriteString(`\"`)
+		case c == '\\' && (i+1 == len(text) || text[i+1] == '"' || text[i+1] == '\\'):
+			b.WriteString(`\\`)
+		default:
+			b.WriteByte(c)
+		}
+	}
+	return b.String()
+}
+
+// FormatFloat formats a number without unneeded decimals, as used in URLs and flags
+func FormatFloat(v float64) string {
+	return strconv.FormatFloat(v, 'f', -1, 64)
+}
+
+// escapeSegment URL-escapes the characters of a path segment that would otherwise
+// change its meaning (slashes, quotes, spaces, ...), but keeps the grammar's separators readable
+func escapeSegment(segment string) string {
+	var b strings.Builder
+	for i := 0; i < len(segment); i++ {
+		c := segment[i]
+		switch {
+		case c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z', c >= '0' && c <= '9':
+			b.WriteByte(c)
+		case strings.IndexByte("-._~:,!$'()*+;=@", c) != -1:
+			b.WriteByte(c)
+		default:
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+	return b.String()
+}
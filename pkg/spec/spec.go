@@ -0,0 +1,331 @@
+// Package spec holds the image description model that is shared by the CLI and the web server.
+// A Spec is parsed from URL paths or command line flags using one grammar, and can be
+// serialized back into a canonical URL path.
+package spec
+
+import (
+	"fmt"
+	"image/color"
+	"math"
+	"math/rand"
+	"slices"
+
+	"github.com/bylexus/imagen/pkg/generator"
+)
+
+// Default values, matching generator.DefaultConfig except for DefaultTileSize: URLs and
+// command line share the former command line default of 36 pixels, the generator uses 16
+const (
+	DefaultWidth      = 256
+	DefaultHeight     = 192
+	DefaultText       = "{w}x{h}"
+	DefaultTextSize   = 20
+	DefaultLineHeight = 1
+	DefaultFormat     = "png"
+	DefaultQuality    = 90
+	DefaultFrameDelay = 100
+	DefaultTileSize   = 36
+	DefaultCenter     = 50
+	DefaultRadius     = 100
+)
+
+// Background is a single background color definition, e.g. "red,blue:45:t:white" for a gradient.
+// Colors are kept as strings, so "random" colors can be resolved for every image.
+type Background struct {
+	Mode      generator.ColorMode
+	Colors    []string // color values (name, hex or "random")
+	Angle     float64  // gradient direction or conic start angle
+	TileSize  int      // for tiled/noise
+	CenterX   float64  // for radial/conic, in percent of the width
+	CenterY   float64  // for radial/conic, in percent of the height
+	Radius    float64  // for radial, in percent of the distance to the farthest corner
+	TextColor string   // optional text color override
+}
+
+// Spec describes an image (or, with multiple backgrounds, a set of image variants)
+type Spec struct {
+	Width       int
+	Height      int
+	Backgrounds []Background // no background means the default solid gray
+	Text        string
+	TextSize    float64
+	TextColor   string // empty means auto
+	TextAngle   float64
+	TextWrap    float64 // maximum line width in percent of the image width, 0 means no wrapping
+	LineHeight  float64
+	Font        string // font name or path, empty means the default font
+	BorderWidth int
+	BorderColor string // empty means black
+	Format      string
+	Quality     int
+	Lossless    bool
+
+	Frames          int
+	FrameDelay      int
+	FrameAngleStep  float64
+	FrameColorShift bool
+
+	Seed         *int64 // seed for all random decisions, nil means non-deterministic
+	SeedFromPath bool   // the seed was derived from the URL (s:path)
+
+	explicit map[string]bool // fields set explicitly while parsing, to detect conflicting values
+}
+
+// New returns a spec with default values
+func New() *Spec {
+	return &Spec{
+		Width:      DefaultWidth,
+		Height:     DefaultHeight,
+		Text:       DefaultText,
+		TextSize:   DefaultTextSize,
+		LineHeight: DefaultLineHeight,
+		Format:     DefaultFormat,
+		Quality:    DefaultQuality,
+		Frames:     1,
+		FrameDelay: DefaultFrameDelay,
+	}
+}
+
+// NewBackground returns a background definition of the given mode with default values
+func NewBackground(mode generator.ColorMode) Background {
+	return Background{
+		Mode:     mode,
+		TileSize: DefaultTileSize,
+		CenterX:  DefaultCenter,
+		CenterY:  DefaultCenter,
+		Radius:   DefaultRadius,
+	}
+}
+
+// Rand returns a random source seeded with the spec's seed, or nil if the spec has no seed
+func (s *Spec) Rand() *rand.Rand {
+	if s.Seed == nil {
+		return nil
+	}
+	return rand.New(rand.NewSource(*s.Seed))
+}
+
+// Validate checks the spec for invalid or conflicting values
+func (s *Spec) Validate() error {
+	if s.Width <= 0 || s.Height <= 0 {
+		return fmt.Errorf("width and height must be positive")
+	}
+	if err := s.checkFinite(); err != nil {
+		return err
+	}
+	if s.TextSize <= 0 {
+		return fmt.Errorf("text size must be positive")
+	}
+	if s.TextWrap < 0 || s.TextWrap > 100 {
+		return fmt.Errorf("text wrap width must be between 0 and 100 percent")
+	}
+	if s.LineHeight <= 0 || s.LineHeight > 10 {
+		return fmt.Errorf("line height must be greater than 0 and at most 10")
+	}
+	if _, err := generator.LookupFont(s.Font); err != nil {
+		return err
+	}
+	if s.BorderWidth < 0 {
+		return fmt.Errorf("border width must not be negative")
+	}
+	if !isSupportedFormat(s.Format) {
+		return fmt.Errorf("unsupported format: %s", s.Format)
+	}
+	if s.Quality < 1 || s.Quality > 100 {
+		return fmt.Errorf("quality must be between 1 and 100")
+	}
+	if s.Frames < 1 {
+		return fmt.Errorf("frames must be at least 1")
+	}
+	if s.FrameDelay < 0 || s.FrameDelay > 65535 {
+		return fmt.Errorf("delay must be between 0 and 65535 ms")
+	}
+	if s.Frames > 1 && !generator.SupportsAnimation(s.Format) {
+		return fmt.Errorf("format %s does not support animation", s.Format)
+	}
+	return nil
+}
+
+// checkFinite returns an error if a number of the spec is NaN or infinite. NaN values
+// pass every range check, so they are rejected before.
+func (s *Spec) checkFinite() error {
+	type number struct {
+		name  string
+		value float64
+	}
+	numbers := []number{
+		{"text size", s.TextSize},
+		{"text angle", s.TextAngle},
+		{"text wrap width", s.TextWrap},
+		{"line height", s.LineHeight},
+		{"frame angle", s.FrameAngleStep},
+	}
+	for _, bg := range s.Backgrounds {
+		numbers = append(numbers,
+			number{"gradient angle", bg.Angle},
+			number{"gradient center", bg.CenterX},
+			number{"gradient center", bg.CenterY},
+			number{"gradient radius", bg.Radius},
+		)
+	}
+	for _, n := range numbers {
+		if math.IsNaN(n.value) || math.IsInf(n.value, 0) {
+			return fmt.Errorf("%s must be a finite number", n.name)
+		}
+	}
+	return nil
+}
+
+// Config creates the generator configuration. If the spec has multiple backgrounds,
+// one of them is chosen randomly. Random decisions are taken from rng, or from the
+// global random source if rng is nil.
+func (s *Spec) Config(rng *rand.Rand) (*generator.ImageConfig, error) {
+	index := 0
+	if len(s.Backgrounds) > 1 {
+		intn := rand.Intn
+		if rng != nil {
+			intn = rng.Intn
+		}
+		index = intn(len(s.Backgrounds))
+	}
+	return s.ConfigWithBackground(index, rng)
+}
+
+// ConfigWithBackground creates the generator configuration using the background at the given index.
+// Random decisions are taken from rng, or from the global random source if rng is nil.
+func (s *Spec) ConfigWithBackground(index int, rng *rand.Rand) (*generator.ImageConfig, error) {
+	if err := s.Validate(); err != nil {
+		return nil, err
+	}
+
+	config := generator.DefaultConfig()
+	config.Width = s.Width
+	config.Height = s.Height
+	config.Text = s.Text
+	config.TextSize = s.TextSize
+	config.TextAngle = s.TextAngle
+	config.TextWrap = s.TextWrap
+	config.LineHeight = s.LineHeight
+	config.FontName = s.Font
+	config.BorderWidth = s.BorderWidth
+	config.Format = s.Format
+	config.Quality = s.Quality
+	config.Lossless = s.Lossless
+	config.Frames = s.Frames
+	config.FrameDelay = s.FrameDelay
+	config.FrameAngleStep = s.FrameAngleStep
+	config.FrameColorShift = s.FrameColorShift
+
+	if len(s.Backgrounds) > 0 {
+		if index < 0 || index >= len(s.Backgrounds) {
+			return nil, fmt.Errorf("background index %d out of range", index)
+		}
+		bg := s.Backgrounds[index]
+
+		colors, err := resolveColors(bg.Colors, rng)
+		if err != nil {
+			return nil, err
+		}
+		config.ColorMode = bg.Mode
+		config.Colors = colors
+		config.GradientAngle = bg.Angle
+		config.TileSize = bg.TileSize
+		config.GradientCenterX = bg.CenterX
+		config.GradientCenterY = bg.CenterY
+		config.GradientRadius = bg.Radius
+
+		// Text color priority: background text color > default text color > auto
+		if bg.TextColor != "" {
+			col, err := generator.ParseColorRand(bg.TextColor, rng)
+			if err != nil {
+				return nil, fmt.Errorf("invalid text color: %w", err)
+			}
+			config.TextColor = &col
+		}
+	}
+
+	if config.TextColor == nil && s.TextColor != "" {
+		col, err := generator.ParseColorRand(s.TextColor, rng)
+		if err != nil {
+			return nil, fmt.Errorf("invalid text color: %w", err)
+		}
+		config.TextColor = &col
+	}
+
+	if s.BorderColor != "" {
+		col, err := generator.ParseColorRand(s.BorderColor, rng)
+		if err != nil {
+			return nil, fmt.Errorf("invalid border color: %w", err)
+		}
+		config.BorderColor = col
+	}
+
+	// The generator gets its own seed from the seeded source, after all other random decisions
+	if rng != nil {
+		seed := rng.Int63()
+		config.Seed = &seed
+	}
+
+	return config, nil
+}
+
+// IsDeterministic returns true if the spec always results in the same image: either it has a seed,
+// or it has at most one background and no random colors or noise
+func (s *Spec) IsDeterministic() bool {
+	if s.Seed != nil {
+		return true
+	}
+	if len(s.Backgrounds) > 1 {
+		return false
+	}
+	for _, bg := range s.Backgrounds {
+		if bg.Mode == generator.ColorModeNoise || bg.HasRandomColor() {
+			return false
+		}
+	}
+	return !generator.IsRandomColor(s.TextColor) && !generator.IsRandomColor(s.BorderColor)
+}
+
+// CanonicalKey returns a string identifying the image of a deterministic spec:
+// the canonical path, with a path-derived seed replaced by its value
+func (s *Spec) CanonicalKey() string {
+	c := *s
+	c.SeedFromPath = false
+	return c.Path()
+}
+
+// HasRandomColor returns true if the background uses a "random" color
+func (b Background) HasRandomColor() bool {
+	for _, c := range b.Colors {
+		if generator.IsRandomColor(c) {
+			return true
+		}
+	}
+	return generator.IsRandomColor(b.TextColor)
+}
+
+// resolveColors parses the color strings, resolving "random" colors with rng
+func resolveColors(colorStrs []string, rng *rand.Rand) ([]color.Color, error) {
+	colors := make([]color.Color, 0, len(colorStrs))
+	for _, colorStr := range colorStrs {
+		col, err := generator.ParseColorRand(colorStr, rng)
+		if err != nil {
+			return nil, fmt.Errorf("invalid color %s: %w", colorStr, err)
+		}
+		colors = append(colors, col)
+	}
+	return colors, nil
+}
+
+// supportedFormats are the output formats the generator can write
+var supportedFormats = []string{"png", "jpeg", "jpg", "webp", "gif", "apng", "svg"}
+
+// SupportedFormats returns the supported output formats
+func SupportedFormats() []string {
+	return slices.Clone(supportedFormats)
+}
+
+// isSupportedFormat returns true if the generator can write the given format
+func isSupportedFormat(format string) bool {
+	return slices.Contains(supportedFormats, format)
+}
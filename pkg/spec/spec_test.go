@@ -0,0 +1,347 @@
+package spec
+
+import (
+	"math"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/bylexus/imagen/pkg/generator"
+)
+
+func TestParseURLRejectsNonFiniteNumbers(t *testing.T) {
+	paths := []string{
+		"/400x300/g:red,blue:NaN",
+		"/400x300/g:red,blue:inf",
+		"/400x300/r:red,blue:-Inf",
+		"/400x300/r:red,blue:NaN,50",
+		"/400x300/k:red,blue:NaN",
+		`/400x300/t:"a",s:NaN`,
+		`/400x300/t:"a",a:Inf`,
+		`/400x300/t:"a",w:NaN`,
+		`/400x300/t:"a",l:inf`,
+		"/400x300/f:gif/a:3,r:NaN",
+	}
+	for _, path := range paths {
+		t.Run(path, func(t *testing.T) {
+			if s, err := ParseURL(path, ""); err == nil {
+				t.Errorf("ParseURL(%q) = %+v, want an error", path, s)
+			}
+		})
+	}
+}
+
+func TestApplyQueryRejectsNonFiniteNumbers(t *testing.T) {
+	queries := []string{
+		"colors=red,blue&angle=NaN",
+		"colors=red,blue&bg=radial&radius=Inf",
+		"colors=red,blue&bg=radial&cx=NaN",
+		"textSize=NaN",
+		"textAngle=-Inf",
+		"textWrap=NaN",
+		"lineHeight=Inf",
+	}
+	for _, query := range queries {
+		t.Run(query, func(t *testing.T) {
+			if s, err := ParseURL("/400x300", query); err == nil {
+				t.Errorf("ParseURL with query %q = %+v, want an error", query, s)
+			}
+		})
+	}
+}
+
+func TestValidateRejectsNonFiniteNumbers(t *testing.T) {
+	tests := []struct {
+		name   string
+		modify func(s *Spec)
+	}{
+		{"text size", func(s *Spec) { s.TextSize = math.NaN() }},
+		{"text angle", func(s *Spec) { s.TextAngle = math.Inf(1) }},
+		{"text wrap width", func(s *Spec) { s.TextWrap = math.NaN() }},
+		{"line height", func(s *Spec) { s.LineHeight = math.NaN() }},
+		{"frame angle", func(s *Spec) { s.FrameAngleStep = math.Inf(-1) }},
+		{"gradient angle", func(s *Spec) {
+			s.Backgrounds = []Background{{Mode: generator.ColorModeGradient, Colors: []string{"red", "blue"}, Angle: math.NaN()}}
+		}},
+		{"gradient center", func(s *Spec) {
+			s.Backgrounds = []Background{{Mode: generator.ColorModeRadial, Colors: []string{"red", "blue"}, CenterX: math.NaN(), Radius: DefaultRadius}}
+		}},
+		{"gradient radius", func(s *Spec) {
+			s.Backgrounds = []Background{{Mode: generator.ColorModeRadial, Colors: []string{"red", "blue"}, Radius: math.Inf(1)}}
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := New()
+			tt.modify(s)
+			err := s.Validate()
+			if err == nil || !strings.Contains(err.Error(), tt.name) {
+				t.Errorf("Validate() = %v, want an error about the %s", err, tt.name)
+			}
+		})
+	}
+}
+
+func TestPathRoundTrip(t *testing.T) {
+	seed := int64(42)
+	tests := []struct {
+		name   string
+		modify func(s *Spec)
+	}{
+		{"defaults", func(s *Spec) {}},
+		{"plain text", func(s *Spec) { s.Text = "Hello World" }},
+		{"quotes", func(s *Spec) { s.Text = `say "hi"` }},
+		{"quote and options", func(s *Spec) { s.Text = `x",s:90,"y` }},
+		{"only a quote", func(s *Spec) { s.Text = `"` }},
+		{"commas and slashes", func(s *Spec) { s.Text = "a,b/c,d" }},
+		{"backslashes", func(s *Spec) { s.Text = `C:\dir\ and \"quoted\" \` }},
+		{"line break escape", func(s *Spec) { s.Text = `one\ntwo` }},
+		{"percent and unicode", func(s *Spec) { s.Text = "100% größer ✓" }},
+		{"empty text", func(s *Spec) { s.Text = "" }},
+		{"text options", func(s *Spec) {
+			s.Text = "wrapped, \"long\" text"
+			s.TextSize = 32.5
+			s.TextColor = "white"
+			s.TextAngle = -30
+			s.TextWrap = 80
+			s.LineHeight = 1.5
+			s.Font = "Go Mono Bold"
+		}},
+		{"font name with spaces", func(s *Spec) { s.Font = "DejaVu Sans" }},
+		{"backgrounds", func(s *Spec) {
+			gradient := NewBackground(generator.ColorModeGradient)
+			gradient.Colors = []string{"red", "00ff00"}
+			gradient.Angle = 45
+			radial := NewBackground(generator.ColorModeRadial)
+			radial.Colors = []string{"white", "black"}
+			radial.CenterX = 20
+			s.Backgrounds = []Background{gradient, radial}
+		}},
+		{"border, animation, format and seed", func(s *Spec) {
+			s.BorderWidth = 3
+			s.BorderColor = "blue"
+			s.Format = "gif"
+			s.Frames = 4
+			s.FrameDelay = 50
+			s.FrameAngleStep = 15
+			s.Seed = &seed
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := New()
+			s.Width, s.Height = 400, 300
+			tt.modify(s)
+
+			path := s.EscapedPath()
+			parsed, err := ParseURL(path, "")
+			if err != nil {
+				t.Fatalf("ParseURL(%q) returned %v", path, err)
+			}
+			parsed.explicit = nil
+			if !reflect.DeepEqual(parsed, s) {
+				t.Errorf("ParseURL(%q) = %+v, want %+v", path, parsed, s)
+			}
+		})
+	}
+}
+
+func TestParseTextEscapes(t *testing.T) {
+	tests := []struct {
+		path string
+		want string
+	}{
+		{`/400x300/t:"a\"b"`, `a"b`},
+		{`/400x300/t:"a\\b"`, `a\b`},
+		{`/400x300/t:"a\nb"`, `a\nb`},
+		{`/400x300/t:"a\\"`, `a\`},
+		{`/400x300/t:"a,\",b",s:30`, `a,",b`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			s, err := ParseURL(tt.path, "")
+			if err != nil {
+				t.Fatalf("ParseURL(%q) returned %v", tt.path, err)
+			}
+			if s.Text != tt.want {
+				t.Errorf("ParseURL(%q).Text = %q, want %q", tt.path, s.Text, tt.want)
+			}
+		})
+	}
+}
+
+func TestQueryBackgroundConflictsWithPath(t *testing.T) {
+	tests := []struct {
+		path    string
+		query   string
+		wantErr bool
+	}{
+		{"/400x300", "colors=red", false},
+		{"/400x300", "colors=red,blue&angle=45", false},
+		{"/400x300/c:red", "", false},
+		{"/400x300/c:red", "colors=blue", true},
+		{"/400x300/c:red", "colors=red", true},
+		{"/400x300/g:red,blue/c:green", "colors=white,black&bg=radial", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.path+"?"+tt.query, func(t *testing.T) {
+			s, err := ParseURL(tt.path, tt.query)
+			if tt.wantErr {
+				if err == nil || !strings.Contains(err.Error(), "conflicting values for background") {
+					t.Errorf("ParseURL() = %v, want a background conflict", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ParseURL() returned %v", err)
+			}
+			if len(s.Backgrounds) != 1 {
+				t.Errorf("ParseURL() has %d backgrounds, want 1", len(s.Backgrounds))
+			}
+		})
+	}
+}
+
+func TestQueryBackgroundReplacesPresetBackground(t *testing.T) {
+	parser := NewParser(nil)
+	if err := parser.AddPreset("card", "/400x300/g:red,blue"); err != nil {
+		t.Fatal(err)
+	}
+	s, err := parser.ParseURL("/p:card", "colors=green")
+	if err != nil {
+		t.Fatalf("ParseURL() returned %v", err)
+	}
+	if got := s.Path(); got != "/400x300/c:green" {
+		t.Errorf("ParseURL().Path() = %s, want /400x300/c:green", got)
+	}
+}
+
+func TestParseRadialAndConicBackgrounds(t *testing.T) {
+	radial := func(colors []string, radius, cx, cy float64, textColor string) Background {
+		bg := NewBackground(generator.ColorModeRadial)
+		bg.Colors, bg.Radius, bg.CenterX, bg.CenterY, bg.TextColor = colors, radius, cx, cy, textColor
+		return bg
+	}
+	conic := func(colors []string, angle, cx, cy float64, textColor string) Background {
+		bg := NewBackground(generator.ColorModeConic)
+		bg.Colors, bg.Angle, bg.CenterX, bg.CenterY, bg.TextColor = colors, angle, cx, cy, textColor
+		return bg
+	}
+	redBlue := []string{"red", "blue"}
+
+	tests := []struct {
+		mode      generator.ColorMode
+		value     string
+		want      Background
+		wantError string
+	}{
+		{generator.ColorModeRadial, "red,blue", radial(redBlue, 100, 50, 50, ""), ""},
+		{generator.ColorModeRadial, "red,blue:80", radial(redBlue, 80, 50, 50, ""), ""},
+		{generator.ColorModeRadial, "red,blue:80:20,50", radial(redBlue, 80, 20, 50, ""), ""},
+		{generator.ColorModeRadial, "red,blue:20,50:80", radial(redBlue, 80, 20, 50, ""), ""},
+		{generator.ColorModeRadial, "red,blue:0,100", radial(redBlue, 100, 0, 100, ""), ""},
+		{generator.ColorModeRadial, "red,#00ff00,blue:150:t:white", radial([]string{"red", "00ff00", "blue"}, 150, 50, 50, "white"), ""},
+		{generator.ColorModeRadial, "red", Background{}, "radial gradient requires at least 2 colors"},
+		{generator.ColorModeRadial, "red,blue:0", Background{}, "radius must be positive"},
+		{generator.ColorModeRadial, "red,blue:-10", Background{}, "radius must be positive"},
+		{generator.ColorModeRadial, "red,blue:big", Background{}, "invalid radial parameter big"},
+		{generator.ColorModeRadial, "red,blue:20,50,10", Background{}, "invalid gradient center 20,50,10"},
+		{generator.ColorModeRadial, "red,blue:x,50", Background{}, "invalid gradient center x,50"},
+		{generator.ColorModeRadial, "red,blue:80:20,50:1", Background{}, "invalid radial format"},
+		{generator.ColorModeConic, "red,blue", conic(redBlue, 0, 50, 50, ""), ""},
+		{generator.ColorModeConic, "red,blue:90", conic(redBlue, 90, 50, 50, ""), ""},
+		{generator.ColorModeConic, "red,blue:-45:25,75", conic(redBlue, -45, 25, 75, ""), ""},
+		{generator.ColorModeConic, "red,blue:25,75:t:black", conic(redBlue, 0, 25, 75, "black"), ""},
+		{generator.ColorModeConic, "red,blue:0", conic(redBlue, 0, 50, 50, ""), ""},
+		{generator.ColorModeConic, "blue", Background{}, "conic gradient requires at least 2 colors"},
+		{generator.ColorModeConic, "red,blue:left", Background{}, "invalid conic parameter left"},
+		{generator.ColorModeConic, "red,blue:nocolor:t:white", Background{}, "invalid conic parameter nocolor"},
+		{generator.ColorModeConic, "red,nocolor", Background{}, "nocolor"},
+	}
+	for _, tt := range tests {
+		t.Run(string(tt.mode)+" "+tt.value, func(t *testing.T) {
+			bg, err := ParseBackground(tt.mode, tt.value)
+			if tt.wantError != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantError) {
+					t.Errorf("ParseBackground() = %v, want an error containing %q", err, tt.wantError)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ParseBackground() returned %v", err)
+			}
+			if !reflect.DeepEqual(bg, tt.want) {
+				t.Errorf("ParseBackground() = %+v, want %+v", bg, tt.want)
+			}
+		})
+	}
+}
+
+func TestRadialAndConicPaths(t *testing.T) {
+	tests := []struct {
+		path     string
+		query    string
+		wantPath string
+	}{
+		{"/400x300/r:red,blue", "", "/400x300/r:red,blue"},
+		{"/400x300/r:red,blue:100:50,50", "", "/400x300/r:red,blue"},
+		{"/400x300/r:red,blue:80:20,50", "", "/400x300/r:red,blue:80:20,50"},
+		{"/400x300/r:red,blue:20,50:80", "", "/400x300/r:red,blue:80:20,50"},
+		{"/400x300/r:red,blue:50,20", "", "/400x300/r:red,blue:50,20"},
+		{"/400x300/r:red,blue:2.5:t:white", "", "/400x300/r:red,blue:2.5:t:white"},
+		{"/400x300/k:red,blue", "", "/400x300/k:red,blue"},
+		{"/400x300/k:red,blue:0:50,50", "", "/400x300/k:red,blue"},
+		{"/400x300/k:red,blue:90:25,75", "", "/400x300/k:red,blue:90:25,75"},
+		{"/400x300/k:red,blue:25,75", "", "/400x300/k:red,blue:25,75"},
+		{"/400x300/r:red,blue/k:red,blue:90", "", "/400x300/r:red,blue/k:red,blue:90"},
+		{"/400x300", "bg=radial&colors=red,blue", "/400x300/r:red,blue"},
+		{"/400x300", "bg=radial&colors=red,blue&radius=80&cx=20&cy=50", "/400x300/r:red,blue:80:20,50"},
+		{"/400x300", "bg=radial&colors=red,blue&cx=20", "/400x300/r:red,blue:20,50"},
+		{"/400x300", "bg=radial&colors=red,blue&cy=10", "/400x300/r:red,blue:50,10"},
+		{"/400x300", "bg=conic&colors=red,blue&angle=90&cx=25&cy=75", "/400x300/k:red,blue:90:25,75"},
+		{"/400x300", "bg=Conic&colors=red,green,blue", "/400x300/k:red,green,blue"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.path+"?"+tt.query, func(t *testing.T) {
+			s, err := ParseURL(tt.path, tt.query)
+			if err != nil {
+				t.Fatalf("ParseURL() returned %v", err)
+			}
+			if got := s.Path(); got != tt.wantPath {
+				t.Errorf("ParseURL().Path() = %s, want %s", got, tt.wantPath)
+			}
+
+			// The canonical path parses to the same spec
+			reparsed, err := ParseURL(s.Path(), "")
+			if err != nil {
+				t.Fatalf("ParseURL(%q) returned %v", s.Path(), err)
+			}
+			if !reflect.DeepEqual(reparsed.Backgrounds, s.Backgrounds) {
+				t.Errorf("backgrounds of %s = %+v, want %+v", s.Path(), reparsed.Backgrounds, s.Backgrounds)
+			}
+		})
+	}
+}
+
+func TestRadialAndConicQueryErrors(t *testing.T) {
+	tests := []struct {
+		query     string
+		wantError string
+	}{
+		{"bg=radial&colors=red", "radial gradient requires at least 2 colors"},
+		{"bg=radial&colors=red,blue&radius=0", "radius must be positive"},
+		{"bg=radial&colors=red,blue&angle=45", "query parameter angle is not supported for radial backgrounds"},
+		{"bg=conic&colors=red,blue&radius=80", "query parameter radius is not supported for conic backgrounds"},
+		{"bg=gradient&colors=red,blue&cx=20", "query parameter cx is not supported for gradient backgrounds"},
+		{"bg=radial&radius=80", "query parameter bg requires colors"},
+		{"colors=red,blue&cy=20", "query parameter cy is not supported for gradient backgrounds"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			_, err := ParseURL("/400x300", tt.query)
+			if err == nil || !strings.Contains(err.Error(), tt.wantError) {
+				t.Errorf("ParseURL() = %v, want an error containing %q", err, tt.wantError)
+			}
+		})
+	}
+}